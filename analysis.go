@@ -0,0 +1,282 @@
+package quadtree
+
+import "sort"
+
+// point is a cell coordinate in the global coordinate system
+type point struct {
+	x, y Dim
+}
+
+// livePoints returns the coordinates of all live cells of qt in reading order (by y, then x)
+func (qt *Quadtree) livePoints() []point {
+	points := make([]point, 0, qt.Population)
+	qt.LiveCells(func(x, y Dim) {
+		points = append(points, point{x, y})
+	})
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].y != points[j].y {
+			return points[i].y < points[j].y
+		}
+		return points[i].x < points[j].x
+	})
+	return points
+}
+
+// pointBounds returns the bounding box of the non empty points
+func pointBounds(points []point) Rect {
+	box := Rect{points[0].x, points[0].y, points[0].x, points[0].y}
+	for _, p := range points {
+		if p.x < box.MinX {
+			box.MinX = p.x
+		}
+		if p.x > box.MaxX {
+			box.MaxX = p.x
+		}
+		if p.y < box.MinY {
+			box.MinY = p.y
+		}
+		if p.y > box.MaxY {
+			box.MaxY = p.y
+		}
+	}
+	return box
+}
+
+// centeredTree returns a tree of the rule of s with the live cells at points, moved so that the center of their
+// bounding box is at the origin
+func centeredTree(points []point, s *ruleSpace) *Quadtree {
+	box := pointBounds(points)
+	minX, minY, maxX, maxY := box.MinX, box.MinY, box.MaxX, box.MaxY
+	centerX := minX + (maxX-minX+1)/2
+	centerY := minY + (maxY-minY+1)/2
+
+	qt := s.emptyTree(1)
+	qt = qt.GrowToFit(minX-centerX, minY-centerY)
+	qt = qt.GrowToFit(maxX-centerX, maxY-centerY)
+	for _, p := range points {
+		qt = qt.SetCell(p.x-centerX, p.y-centerY, 1)
+	}
+	return qt
+}
+
+// Components partitions the live cells of qt into groups that are separated by at least gap empty cells in all directions.
+// Each group is returned as its own tree centered at the origin. Groups are ordered by their first cell in reading order.
+// With a gap of 1 the groups are the 8-connected components of the pattern.
+func (qt *Quadtree) Components(gap Dim) []*Quadtree {
+	var components []*Quadtree
+	for _, group := range qt.componentPoints(gap) {
+		components = append(components, centeredTree(group, qt.space))
+	}
+	return components
+}
+
+// componentPoints returns the live cells of qt grouped as described by Components.
+// The cells are sorted into square buckets with a side of gap, so the neighbors of a cell within the gap are found in
+// the 3x3 buckets around its own and the cost follows the population, not the area around each cell.
+// Grouped cells are removed from their bucket, so every cell is added once.
+func (qt *Quadtree) componentPoints(gap Dim) [][]point {
+	points := qt.livePoints()
+	side := gap
+	if side < 1 {
+		side = 1
+	}
+	bucketOf := func(p point) point {
+		return point{floorDiv(p.x, side), floorDiv(p.y, side)}
+	}
+	buckets := make(map[point][]point)
+	for _, p := range points {
+		b := bucketOf(p)
+		buckets[b] = append(buckets[b], p)
+	}
+	grouped := make(map[point]bool, len(points))
+
+	var groups [][]point
+	for _, start := range points {
+		if grouped[start] {
+			continue
+		}
+		grouped[start] = true
+		group := []point{start}
+		for i := 0; i < len(group); i++ {
+			p := group[i]
+			b := bucketOf(p)
+			for by := b.y - 1; by <= b.y+1; by++ {
+				for bx := b.x - 1; bx <= b.x+1; bx++ {
+					neighbors := buckets[point{bx, by}]
+					kept := neighbors[:0]
+					for _, n := range neighbors {
+						if grouped[n] {
+							continue
+						}
+						if n.x-p.x <= gap && p.x-n.x <= gap && n.y-p.y <= gap && p.y-n.y <= gap {
+							grouped[n] = true
+							group = append(group, n)
+						} else {
+							kept = append(kept, n)
+						}
+					}
+					if len(neighbors) > 0 {
+						buckets[point{bx, by}] = kept
+					}
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// floorDiv returns a / b rounded towards negative infinity, b has to be positive
+func floorDiv(a, b Dim) Dim {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// symmetries are the 8 rotations and reflections of the plane
+var symmetries = []func(p point) point{
+	func(p point) point { return point{p.x, p.y} },
+	func(p point) point { return point{-p.y, p.x} },
+	func(p point) point { return point{-p.x, -p.y} },
+	func(p point) point { return point{p.y, -p.x} },
+	func(p point) point { return point{-p.x, p.y} },
+	func(p point) point { return point{p.x, -p.y} },
+	func(p point) point { return point{p.y, p.x} },
+	func(p point) point { return point{-p.y, -p.x} },
+}
+
+// canonicalKey returns the RLE body of the pattern formed by points in the orientation with the smallest key,
+// so that rotated or reflected copies of an object get the same key.
+func canonicalKey(points []point) string {
+	key := ""
+	transformed := make([]point, len(points))
+	for i, symmetry := range symmetries {
+		for j, p := range points {
+			transformed[j] = symmetry(p)
+		}
+		if k := encodeRLEBody(transformed); i == 0 || len(k) < len(key) || len(k) == len(key) && k < key {
+			key = k
+		}
+	}
+	return key
+}
+
+// ClassifyAsh counts the objects of a stabilized pattern. Each 8-connected component is identified by its canonical
+// RLE body, so "2o$2o!" counts the blocks. Different phases of an oscillator are counted as different objects.
+func (qt *Quadtree) ClassifyAsh() map[string]int {
+	ash := make(map[string]int)
+	for _, group := range qt.componentPoints(1) {
+		ash[canonicalKey(group)]++
+	}
+	return ash
+}
+
+// expand returns qt grown until all live cells lie within its center half, so a NextGen step can't lose cells at the border
+func (qt *Quadtree) expand() *Quadtree {
+	for qt.Level < 2 || !qt.LiveFitsInLevel(qt.Level-1) {
+		qt = qt.grow()
+	}
+	return qt
+}
+
+// minShrinkLevel is the level below which Shrink doesn't go
+const minShrinkLevel = 3
+
+// Shrink returns the smallest tree at the same position as qt that still contains all of its live cells.
+// The root is replaced by its center as long as the outer ring is dead and the level stays at least minShrinkLevel.
+// qt itself is returned if already the first step would lose a live cell.
+func (qt *Quadtree) Shrink() *Quadtree {
+	for qt.Level > minShrinkLevel && qt.LiveFitsInLevel(qt.Level-1) {
+		qt = qt.centeredSubnode()
+	}
+	return qt
+}
+
+// Advance returns the next generation of qt like NextGen, but without losing cells at the border and without
+// letting the tree grow for ever: it grows only when live cells reach the outer ring, the border half of qt,
+// and shrinks while they fit in the center quarter. Still lifes and oscillators keep their level and so their
+// cached next generations.
+func (qt *Quadtree) Advance() *Quadtree {
+	next := qt.expand().NextGen()
+	for next.Level > minShrinkLevel && next.LiveFitsInLevel(next.Level-2) {
+		next = next.centeredSubnode()
+	}
+	return next
+}
+
+// FirstAlive returns the first generation within maxGen generations at which cell (x,y) is alive.
+// Generation 0 is qt itself. The universe grows as needed, so no cells are lost at the border.
+func (qt *Quadtree) FirstAlive(x, y Dim, maxGen int) (gen int, ok bool) {
+	for gen = 0; gen <= maxGen; gen++ {
+		if gen > 0 {
+			qt = qt.expand().NextGen()
+		}
+		if qt.cellOrDead(x, y) != 0 {
+			return gen, true
+		}
+	}
+	return 0, false
+}
+
+// BoundingBoxSeries returns the bounding box of the live cells after each of the next gens generations.
+// The universe grows as needed. Generations without live cells have an empty Rect.
+func (qt *Quadtree) BoundingBoxSeries(gens int) []Rect {
+	series := make([]Rect, 0, gens)
+	for i := 0; i < gens; i++ {
+		qt = qt.expand().NextGen()
+		box, ok := qt.boundingBox()
+		if !ok {
+			box = Rect{0, 0, -1, -1}
+		}
+		series = append(series, box)
+	}
+	return series
+}
+
+// FindCycle steps qt until it reaches a state it has been in before, for at most maxGen generations.
+// It returns the number of generations before the cycle starts, the period and the states of one cycle,
+// beginning with the generation preamble. ok is false if no state repeated within maxGen generations.
+// States are compared at a fixed position, so a spaceship never repeats. The universe grows as needed.
+func (qt *Quadtree) FindCycle(maxGen int) (preamble int, period int, states []*Quadtree, ok bool) {
+	type state struct {
+		level  uint
+		childs Childs
+	}
+	// the childs of a shrunk tree are cached nodes and identify the state even if the tree itself isn't cached
+	qt = qt.Shrink()
+	history := []*Quadtree{qt}
+	seen := map[state]int{{qt.Level, qt.Childs}: 0}
+	for gen := 1; gen <= maxGen; gen++ {
+		qt = qt.expand().NextGen().Shrink()
+		if first, ok := seen[state{qt.Level, qt.Childs}]; ok {
+			return first, gen - first, history[first:], true
+		}
+		seen[state{qt.Level, qt.Childs}] = gen
+		history = append(history, qt)
+	}
+	return 0, 0, nil, false
+}
+
+// Period returns the period of the cycle qt settles into within maxGen generations, 1 for a still life.
+// It's FindCycle without the states, so a pattern that only becomes periodic after some generations counts as well,
+// while spaceships never do. found is false if no state repeated within maxGen generations.
+func (qt *Quadtree) Period(maxGen uint) (period uint, found bool) {
+	_, p, _, found := qt.FindCycle(int(maxGen))
+	return uint(p), found
+}
+
+// CenterOfMass returns the average coordinates of the live cells of the root qt, (0,0) for an empty tree.
+// The cells are summed up while they're visited, they're never collected.
+func (qt *Quadtree) CenterOfMass() (x, y float64) {
+	if qt.IsEmpty() {
+		return 0, 0
+	}
+	var sumX, sumY float64
+	qt.LiveCells(func(x, y Dim) {
+		sumX += float64(x)
+		sumY += float64(y)
+	})
+	return sumX / float64(qt.Population), sumY / float64(qt.Population)
+}
@@ -0,0 +1,257 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// treeWithCells returns a tree grown to fit and with the given cells set to live
+func treeWithCells(cells ...[2]Dim) *Quadtree {
+	qt := EmptyTree(1)
+	for _, c := range cells {
+		qt = qt.GrowToFit(c[0], c[1])
+		qt = qt.SetCell(c[0], c[1], 1)
+	}
+	return qt
+}
+
+func TestComponents(t *testing.T) {
+	// a block in the north west and a blinker in the south east
+	qt := treeWithCells(
+		[2]Dim{-10, -10}, [2]Dim{-9, -10}, [2]Dim{-10, -9}, [2]Dim{-9, -9},
+		[2]Dim{9, 10}, [2]Dim{10, 10}, [2]Dim{11, 10},
+	)
+
+	components := qt.Components(1)
+	assert.Len(t, components, 2)
+	assert.Equal(t, Dim(4), components[0].Population)
+	assert.Equal(t, Dim(1), components[0].Cell(0, 0))
+	assert.Equal(t, Dim(1), components[0].Cell(-1, -1))
+	assert.Equal(t, Dim(3), components[1].Population)
+	assert.Equal(t, Dim(1), components[1].Cell(-1, 0))
+	assert.Equal(t, Dim(1), components[1].Cell(1, 0))
+
+	// with a big gap everything is one group
+	assert.Len(t, qt.Components(20), 1)
+
+	assert.Empty(t, EmptyTree(4).Components(1))
+}
+
+func TestComponentsGap(t *testing.T) {
+	// two cells with one empty cell in between
+	qt := treeWithCells([2]Dim{0, 0}, [2]Dim{2, 0})
+	assert.Len(t, qt.Components(1), 2)
+	assert.Len(t, qt.Components(2), 1)
+
+	// huge gaps don't probe every position around a cell
+	far := treeWithCells([2]Dim{-1 << 30, 0}, [2]Dim{1 << 30, 5}, [2]Dim{0, -1 << 30})
+	assert.Len(t, far.Components(1<<30), 2)
+	assert.Len(t, far.Components(1<<31), 1)
+	assert.Len(t, far.Components(1<<30-1), 3)
+
+	// the same groups as joining every pair of cells within the gap
+	for _, gap := range []Dim{0, 1, 2, 3, 5} {
+		qt, _ := treeWithRandomPattern(5)
+		qt = qt.ClearRegion(-2, -16, 2, 15)
+		points := qt.livePoints()
+		// reference: merge the labels of all close pairs until nothing changes
+		label := make(map[point]int)
+		for i, p := range points {
+			label[p] = i
+		}
+		for changed := true; changed; {
+			changed = false
+			for _, a := range points {
+				for _, b := range points {
+					close := a.x-b.x <= gap && b.x-a.x <= gap && a.y-b.y <= gap && b.y-a.y <= gap
+					if close && label[b] < label[a] {
+						label[a] = label[b]
+						changed = true
+					}
+				}
+			}
+		}
+		group := make(map[point]int)
+		for i, g := range qt.componentPoints(gap) {
+			for _, p := range g {
+				group[p] = i
+			}
+		}
+		assert.Equal(t, len(points), len(group), "gap %v", gap)
+		for _, a := range points {
+			for _, b := range points {
+				assert.Equal(t, label[a] == label[b], group[a] == group[b], "gap %v: %v and %v", gap, a, b)
+			}
+		}
+	}
+}
+
+func TestClassifyAsh(t *testing.T) {
+	qt := treeWithCells(
+		// block
+		[2]Dim{-10, -10}, [2]Dim{-9, -10}, [2]Dim{-10, -9}, [2]Dim{-9, -9},
+		// another block
+		[2]Dim{20, 20}, [2]Dim{21, 20}, [2]Dim{20, 21}, [2]Dim{21, 21},
+		// horizontal blinker
+		[2]Dim{9, 10}, [2]Dim{10, 10}, [2]Dim{11, 10},
+		// vertical blinker
+		[2]Dim{-20, 10}, [2]Dim{-20, 11}, [2]Dim{-20, 12},
+	)
+	assert.Equal(t, map[string]int{"2o$2o!": 2, "3o!": 2}, qt.ClassifyAsh())
+	assert.Empty(t, EmptyTree(3).ClassifyAsh())
+}
+
+func TestCanonicalKey(t *testing.T) {
+	// all orientations of a glider have the same key
+	points := glider().livePoints()
+	key := canonicalKey(points)
+	for _, symmetry := range symmetries {
+		transformed := make([]point, len(points))
+		for i, p := range points {
+			transformed[i] = symmetry(point{p.x + 7, p.y - 3})
+		}
+		assert.Equal(t, key, canonicalKey(transformed))
+	}
+	assert.NotEqual(t, key, canonicalKey(points[1:]))
+}
+
+func TestExpand(t *testing.T) {
+	qt := treeWithCells([2]Dim{3, 0})
+	assert.Equal(t, uint(3), qt.Level)
+	qt = qt.expand()
+	assert.Equal(t, uint(4), qt.Level)
+	assert.Equal(t, qt, qt.expand())
+	assert.Equal(t, uint(2), EmptyTree(1).expand().Level)
+}
+
+func TestAdvance(t *testing.T) {
+	qt := blinker().GrowToFit(1000, 1000).Advance()
+	assert.Equal(t, uint(minShrinkLevel), qt.Level)
+	assert.True(t, qt == qt.Advance().Advance())
+	block := Block().GrowToFit(10, 10).Advance()
+	assert.True(t, block == block.Advance())
+
+	// the glider never hits the border and the level only grows with its distance from the origin
+	qt = Glider()
+	big := Glider().GrowToFit(64, 64)
+	for gen := 1; gen <= 100; gen++ {
+		qt = qt.Advance()
+		big = big.NextGen()
+	}
+	assert.Equal(t, big.livePoints(), qt.livePoints())
+	assert.Equal(t, uint(7), qt.Level)
+}
+
+func TestFirstAlive(t *testing.T) {
+	gen, ok := blinker().FirstAlive(0, -1, 10)
+	assert.True(t, ok)
+	assert.Equal(t, 1, gen)
+
+	gen, ok = blinker().FirstAlive(0, 0, 10)
+	assert.True(t, ok)
+	assert.Equal(t, 0, gen)
+
+	_, ok = blinker().FirstAlive(0, 2, 10)
+	assert.False(t, ok)
+
+	// the glider reaches (30,30) far beyond its initial tree
+	qt := glider()
+	expect := 0
+	for big := qt.GrowToFit(64, 64); big.Cell(30, 30) == 0; expect++ {
+		big = big.NextGen()
+	}
+	gen, ok = qt.FirstAlive(30, 30, 200)
+	assert.True(t, ok)
+	assert.Equal(t, expect, gen)
+}
+
+func TestBoundingBoxSeries(t *testing.T) {
+	series := blinker().BoundingBoxSeries(3)
+	horizontal, _ := blinker().boundingBox()
+	vertical := Rect{horizontal.MinX + 1, horizontal.MinY - 1, horizontal.MaxX - 1, horizontal.MaxY + 1}
+	assert.Equal(t, []Rect{vertical, horizontal, vertical}, series)
+
+	start, _ := glider().boundingBox()
+	series = glider().BoundingBoxSeries(8)
+	assert.Len(t, series, 8)
+	assert.Equal(t, Rect{start.MinX + 2, start.MinY + 2, start.MaxX + 2, start.MaxY + 2}, series[7])
+
+	series = treeWithCells([2]Dim{0, 0}).BoundingBoxSeries(2)
+	assert.True(t, series[0].Empty())
+	assert.True(t, series[1].Empty())
+}
+
+func TestFindCycle(t *testing.T) {
+	preamble, period, states, ok := blinker().FindCycle(10)
+	assert.True(t, ok)
+	assert.Equal(t, 0, preamble)
+	assert.Equal(t, 2, period)
+	assert.Len(t, states, 2)
+	assert.Equal(t, blinker().livePoints(), states[0].livePoints())
+
+	// three cells in an L become a block after one generation
+	preamble, period, states, ok = treeWithCells([2]Dim{0, 0}, [2]Dim{1, 0}, [2]Dim{0, 1}).FindCycle(10)
+	assert.True(t, ok)
+	assert.Equal(t, 1, preamble)
+	assert.Equal(t, 1, period)
+	assert.Equal(t, Dim(4), states[0].Population)
+
+	_, _, _, ok = glider().FindCycle(50)
+	assert.False(t, ok)
+}
+
+func TestPeriod(t *testing.T) {
+	block := treeWithCells([2]Dim{0, 0}, [2]Dim{1, 0}, [2]Dim{0, 1}, [2]Dim{1, 1})
+	period, found := block.Period(5)
+	assert.True(t, found)
+	assert.Equal(t, uint(1), period)
+
+	period, found = blinker().GrowToFit(100, 100).Period(5)
+	assert.True(t, found)
+	assert.Equal(t, uint(2), period)
+
+	// the blinker needs two generations to repeat
+	_, found = blinker().Period(1)
+	assert.False(t, found)
+	_, found = glider().Period(50)
+	assert.False(t, found)
+}
+
+func TestShrink(t *testing.T) {
+	qt := blinker().GrowToFit(1000, 1000)
+	shrunk := qt.Shrink()
+	assert.Equal(t, uint(minShrinkLevel), shrunk.Level)
+	assert.Equal(t, blinker(), shrunk)
+
+	// a cell in the outer ring keeps the root
+	qt = qt.SetCell(-1024, 0, 1)
+	assert.True(t, qt == qt.Shrink())
+
+	qt = treeWithCells([2]Dim{100, 0}).GrowToFit(5000, 0)
+	shrunk = qt.Shrink()
+	assert.Equal(t, uint(8), shrunk.Level)
+	assert.Equal(t, Dim(1), shrunk.Cell(100, 0))
+
+	assert.Equal(t, uint(minShrinkLevel), EmptyTree(20).Shrink().Level)
+}
+
+func TestCenterOfMass(t *testing.T) {
+	x, y := Block().CenterOfMass()
+	assert.Equal(t, -0.5, x)
+	assert.Equal(t, -0.5, y)
+	x, y = EmptyTree(3).CenterOfMass()
+	assert.Equal(t, 0.0, x)
+	assert.Equal(t, 0.0, y)
+
+	qt := Glider().GrowToFit(64, 64)
+	startX, startY := qt.CenterOfMass()
+	for period := 1; period <= 10; period++ {
+		for gen := 0; gen < 4; gen++ {
+			qt = qt.NextGen()
+		}
+		x, y := qt.CenterOfMass()
+		assert.InDelta(t, startX+float64(period), x, 1e-9)
+		assert.InDelta(t, startY+float64(period), y, 1e-9)
+	}
+}
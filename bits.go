@@ -0,0 +1,39 @@
+package quadtree
+
+import "fmt"
+
+// FromBits returns a tree of the given level with the 2^level x 2^level cells read row by row from bits.
+// Every word holds 64 cells with the first cell in its most significant bit. Grids with less than 64 cells
+// occupy the low bits of a single word, so FromBits(2, []uint64{0xF000}) has a live top row.
+func FromBits(level uint, bits []uint64) *Quadtree {
+	if level < 1 || level > 31 {
+		panic(fmt.Sprintf("FromBits needs a level between 1 and 31, got %v", level))
+	}
+	size := Dim(1) << level
+	perWord := size * size
+	if perWord > 64 {
+		perWord = 64
+	}
+	if Dim(len(bits))*perWord < size*size {
+		panic(fmt.Sprintf("FromBits needs %v bits for level %v, got %v", size*size, level, len(bits)*64))
+	}
+	return fromBits(level, 0, 0, size, perWord, bits)
+}
+
+// fromBits builds the node of the given level whose north west corner is cell (x,y) of the grid
+func fromBits(level uint, x, y, size, perWord Dim, bits []uint64) *Quadtree {
+	if level == 0 {
+		i := y*size + x
+		if bits[i/perWord]>>uint(perWord-1-i%perWord)&1 != 0 {
+			return LiveLeaf()
+		}
+		return DeadLeaf()
+	}
+	half := Dim(1) << (level - 1)
+	return NewTree(Childs{
+		NW: fromBits(level-1, x, y, size, perWord, bits),
+		NE: fromBits(level-1, x+half, y, size, perWord, bits),
+		SW: fromBits(level-1, x, y+half, size, perWord, bits),
+		SE: fromBits(level-1, x+half, y+half, size, perWord, bits),
+	})
+}
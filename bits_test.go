@@ -0,0 +1,36 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromBits(t *testing.T) {
+	// 1000
+	// 0100
+	// 0000
+	// 0001
+	qt := FromBits(2, []uint64{0x8401})
+	assert.Equal(t, Dim(3), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(-2, -2))
+	assert.Equal(t, Dim(1), qt.Cell(-1, -1))
+	assert.Equal(t, Dim(1), qt.Cell(1, 1))
+
+	// level 3 uses one byte per row
+	qt = FromBits(3, []uint64{0x000000081C000000})
+	assert.Equal(t, blinker().SetCell(0, -1, 1), qt)
+
+	// compare with setting every cell
+	words := []uint64{0x0123456789abcdef, 0xfedcba9876543210, 0xdeadbeefcafebabe, 0x1}
+	expect := EmptyTree(4)
+	for i := Dim(0); i < 256; i++ {
+		if words[i/64]>>uint(63-i%64)&1 != 0 {
+			expect = expect.SetCell(i%16-8, i/16-8, 1)
+		}
+	}
+	assert.Equal(t, expect, FromBits(4, words))
+
+	assert.Panics(t, func() { FromBits(4, words[:3]) })
+	assert.Panics(t, func() { FromBits(0, words) })
+}
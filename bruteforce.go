@@ -0,0 +1,35 @@
+package quadtree
+
+// BruteForceStep computes the next generation of qt by counting the neighbors of every single cell.
+// It uses no recursion and no cache and serves as reference for validating NextGen, which it matches:
+// the result has the same level as qt and cells beyond the border of qt are dead.
+// Its cost is proportional to the area of qt, so it's only suitable for small trees.
+func BruteForceStep(qt *Quadtree) *Quadtree {
+	edge := Dim(1) << qt.Level
+	origin := qt.origin()
+	cells := make([][]bool, edge+2) // with a dead border
+	for i := range cells {
+		cells[i] = make([]bool, edge+2)
+	}
+	qt.LiveCells(func(x, y Dim) {
+		cells[y-origin+1][x-origin+1] = true
+	})
+
+	next := qt.space.emptyTree(qt.Level)
+	for y := Dim(1); y <= edge; y++ {
+		for x := Dim(1); x <= edge; x++ {
+			neighbors := 0
+			for dy := Dim(-1); dy <= 1; dy++ {
+				for dx := Dim(-1); dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && cells[y+dy][x+dx] {
+						neighbors++
+					}
+				}
+			}
+			if qt.Rule().nextState(cells[y][x], neighbors) {
+				next = next.SetCell(x-1+origin, y-1+origin, 1)
+			}
+		}
+	}
+	return next
+}
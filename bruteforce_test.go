@@ -0,0 +1,30 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBruteForceStep(t *testing.T) {
+	qt := blinker()
+	next := BruteForceStep(qt)
+	assert.Equal(t, uint(3), next.Level)
+	assert.Equal(t, Dim(3), next.Population)
+	assert.Equal(t, Dim(1), next.Cell(0, -1))
+	assert.Equal(t, Dim(1), next.Cell(0, 1))
+	assert.Equal(t, qt, BruteForceStep(next))
+}
+
+func TestNextGenMatchesBruteForce(t *testing.T) {
+	for level := uint(2); level <= 5; level++ {
+		for i := 0; i < 20; i++ {
+			qt, _ := treeWithRandomPattern(level)
+			for gen := 0; gen < 4; gen++ {
+				expect := BruteForceStep(qt)
+				qt = qt.NextGen()
+				assert.Equal(t, expect, qt, "level %v generation %v", level, gen)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package quadtree
+
+import (
+	"bufio"
+	"io"
+)
+
+// ExportCells writes the live cells of qt in plaintext (.cells) format. See ExportCellsWith.
+func (qt *Quadtree) ExportCells(w io.Writer) error {
+	return qt.ExportCellsWith(w, WriteOptions{})
+}
+
+// ExportCellsWith writes the live cells of qt in plaintext (.cells) format: one line per row of their bounding box
+// with 'O' for live and '.' for dead cells, trailing dead cells are left out. The rows are streamed from
+// FindLifeRuns, so even huge patterns are written with bounded memory.
+func (qt *Quadtree) ExportCellsWith(w io.Writer, opts WriteOptions) error {
+	bw := bufio.NewWriter(opts.writer(w))
+	box, ok := qt.boundingBox()
+	if !ok {
+		return bw.Flush()
+	}
+	writeRun := func(count Dim, c byte) {
+		for i := Dim(0); i < count; i++ {
+			bw.WriteByte(c)
+		}
+	}
+	x, y := box.MinX, box.MinY
+	qt.FindLifeRuns(func(row Dim, s Span) {
+		if row != y {
+			writeRun(row-y, '\n')
+			x, y = box.MinX, row
+		}
+		writeRun(s.Start-x, '.')
+		writeRun(s.End-s.Start+1, 'O')
+		x = s.End + 1
+	})
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
@@ -0,0 +1,24 @@
+package quadtree
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCells(t *testing.T) {
+	var b strings.Builder
+	assert.NoError(t, glider().ExportCells(&b))
+	assert.Equal(t, ".O\n..O\nOOO\n", b.String())
+
+	// rows without live cells are empty lines
+	b.Reset()
+	qt := treeWithCells([2]Dim{0, 0}, [2]Dim{2, 3})
+	assert.NoError(t, qt.ExportCellsWith(&b, WriteOptions{LineEnding: "\r\n"}))
+	assert.Equal(t, "O\r\n\r\n\r\n..O\r\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, EmptyTree(3).ExportCells(&b))
+	assert.Equal(t, "", b.String())
+}
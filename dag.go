@@ -0,0 +1,109 @@
+package quadtree
+
+import "unsafe"
+
+// collectNodes adds every unique node of qt's DAG to set, identified by pointer
+func (qt *Quadtree) collectNodes(set map[*Quadtree]struct{}) {
+	if _, ok := set[qt]; ok {
+		return
+	}
+	set[qt] = struct{}{}
+	if qt.IsLeaf() {
+		return
+	}
+	for _, child := range qt.childs() {
+		child.collectNodes(set)
+	}
+}
+
+// SharedNodeCount returns the number of unique nodes (by pointer identity) that appear in the DAGs of both a and b.
+// Successive generations of a stable pattern should share almost all of their nodes.
+func SharedNodeCount(a, b *Quadtree) int {
+	nodesA := make(map[*Quadtree]struct{})
+	a.collectNodes(nodesA)
+	nodesB := make(map[*Quadtree]struct{})
+	b.collectNodes(nodesB)
+
+	count := 0
+	for node := range nodesB {
+		if _, ok := nodesA[node]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+// MemStats returns the number of unique nodes of qt's DAG and an estimate of the memory they take.
+// The cached next generations aren't counted. Compare it with the Size of CacheStats to tell a big pattern
+// from a cache full of nodes of old generations.
+func (qt *Quadtree) MemStats() (nodes int, approxBytes int64) {
+	set := make(map[*Quadtree]struct{})
+	qt.collectNodes(set)
+	return len(set), int64(len(set)) * int64(unsafe.Sizeof(Quadtree{}))
+}
+
+// DAGEdges returns the unique nodes of qt's DAG with qt at index 0 and, for each node, the indices of its
+// NW, NE, SW and SE childs in nodes. Leaves have no childs, their edges are all -1.
+func (qt *Quadtree) DAGEdges() (nodes []*Quadtree, edges [][4]int) {
+	index := make(map[*Quadtree]int)
+	var visit func(node *Quadtree) int
+	visit = func(node *Quadtree) int {
+		if i, ok := index[node]; ok {
+			return i
+		}
+		i := len(nodes)
+		index[node] = i
+		nodes = append(nodes, node)
+		edges = append(edges, [4]int{-1, -1, -1, -1})
+		if node.IsLeaf() {
+			return i
+		}
+		for j, child := range []*Quadtree{node.NW, node.NE, node.SW, node.SE} {
+			c := visit(child) // may grow edges, so index it afterwards
+			edges[i][j] = c
+		}
+		return i
+	}
+	visit(qt)
+	return nodes, edges
+}
+
+// leaf hashes of CanonicalHash
+const (
+	deadLeafHash uint64 = 0x9e3779b97f4a7c15
+	liveLeafHash uint64 = 0xc2b2ae3d27d4eb4f
+)
+
+// CanonicalHash returns a hash of the cells and level of qt that, unlike the pointers of the cache keys, is the same
+// in every run and for every copy of the tree, so it can be used as content address. The rule isn't part of the hash.
+func (qt *Quadtree) CanonicalHash() uint64 {
+	return qt.canonicalHash(make(map[*Quadtree]uint64))
+}
+
+func (qt *Quadtree) canonicalHash(memo map[*Quadtree]uint64) uint64 {
+	if qt.IsLeaf() {
+		switch qt.state {
+		case 0:
+			return deadLeafHash
+		case 1:
+			return liveLeafHash
+		}
+		return mix64(liveLeafHash + uint64(qt.state))
+	}
+	if h, ok := memo[qt]; ok {
+		return h
+	}
+	h := uint64(qt.Level)
+	for _, child := range qt.childs() {
+		h = mix64(h + child.canonicalHash(memo))
+	}
+	memo[qt] = h
+	return h
+}
+
+// mix64 is the finalizer of SplitMix64, every input bit affects every output bit
+func mix64(h uint64) uint64 {
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return h ^ h>>31
+}
@@ -0,0 +1,83 @@
+package quadtree
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedNodeCount(t *testing.T) {
+	// an empty tree consists of one node per level
+	assert.Equal(t, 8, SharedNodeCount(EmptyTree(7), EmptyTree(7)))
+	assert.Equal(t, 4, SharedNodeCount(EmptyTree(3), EmptyTree(5)))
+
+	// a block is a still life, so its next generation is made of the very same nodes
+	qt := EmptyTree(3)
+	for _, c := range [][2]Dim{{0, 0}, {-1, 0}, {0, -1}, {-1, -1}} {
+		qt = qt.SetCell(c[0], c[1], 1)
+	}
+	nodes := make(map[*Quadtree]struct{})
+	qt.collectNodes(nodes)
+	assert.Equal(t, len(nodes), SharedNodeCount(qt, qt.NextGen()))
+
+	// only the empty level 1 node and the dead leaf are shared with an empty tree
+	assert.Equal(t, 2, SharedNodeCount(qt, EmptyTree(3)))
+}
+
+func TestMemStats(t *testing.T) {
+	size := int64(unsafe.Sizeof(Quadtree{}))
+	nodes, bytes := EmptyTree(7).MemStats()
+	assert.Equal(t, 8, nodes)
+	assert.Equal(t, 8*size, bytes)
+
+	// stepping adds to the cache but not to the pattern
+	qt := blinker().GrowToFit(1000, 1000)
+	before, _ := qt.MemStats()
+	qt.NextGen()
+	after, _ := qt.MemStats()
+	assert.Equal(t, before, after)
+	assert.True(t, before < qt.CacheStats().Size)
+}
+
+func TestDAGEdges(t *testing.T) {
+	// one node per level, each pointing four times to the next lower level
+	nodes, edges := EmptyTree(3).DAGEdges()
+	assert.Len(t, nodes, 4)
+	assert.Equal(t, [][4]int{{1, 1, 1, 1}, {2, 2, 2, 2}, {3, 3, 3, 3}, {-1, -1, -1, -1}}, edges)
+	assert.True(t, nodes[0] == EmptyTree(3))
+	assert.True(t, nodes[3] == DeadLeaf())
+
+	qt := blinker()
+	nodes, edges = qt.DAGEdges()
+	set := make(map[*Quadtree]struct{})
+	qt.collectNodes(set)
+	assert.Len(t, nodes, len(set))
+	for i, node := range nodes {
+		if node.IsLeaf() {
+			assert.Equal(t, [4]int{-1, -1, -1, -1}, edges[i])
+			continue
+		}
+		assert.True(t, node.NW == nodes[edges[i][0]])
+		assert.True(t, node.NE == nodes[edges[i][1]])
+		assert.True(t, node.SW == nodes[edges[i][2]])
+		assert.True(t, node.SE == nodes[edges[i][3]])
+	}
+}
+
+func TestCanonicalHash(t *testing.T) {
+	old := nodeMap
+	defer SetNodeCache(old)
+	qt := glider().GrowToFit(40, 40)
+	h := qt.CanonicalHash()
+	assert.Equal(t, h, qt.Detach().CanonicalHash())
+	SetNodeCache(nil)
+	rebuilt := glider().GrowToFit(40, 40)
+	assert.False(t, rebuilt == qt)
+	assert.Equal(t, h, rebuilt.CanonicalHash())
+
+	assert.NotEqual(t, h, rebuilt.NextGen().CanonicalHash())
+	assert.NotEqual(t, h, rebuilt.FlipX().CanonicalHash())
+	assert.NotEqual(t, EmptyTree(3).CanonicalHash(), EmptyTree(4).CanonicalHash())
+	assert.Equal(t, blinker().CanonicalHash(), blinker().NextGen().NextGen().Shrink().CanonicalHash())
+}
@@ -0,0 +1,63 @@
+package quadtree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ParseError is returned by the pattern readers when the input is malformed
+type ParseError struct {
+	Line int // 1-based line number of the input
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// parseErrorf returns a *ParseError for line with a formatted message
+func parseErrorf(line int, format string, args ...interface{}) *ParseError {
+	return &ParseError{line, fmt.Sprintf(format, args...)}
+}
+
+// WriteOptions configures the text format writers
+type WriteOptions struct {
+	LineEnding string // "\n" if empty, "\r\n" for Windows tools
+}
+
+// writer returns w wrapped so that "\n" written to it is replaced with the configured line ending
+func (opts WriteOptions) writer(w io.Writer) io.Writer {
+	if opts.LineEnding == "" || opts.LineEnding == "\n" {
+		return w
+	}
+	return &lineEndingWriter{w, []byte(opts.LineEnding)}
+}
+
+// lineEndingWriter replaces every "\n" with ending
+type lineEndingWriter struct {
+	w      io.Writer
+	ending []byte
+}
+
+func (lw *lineEndingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			n, err := lw.w.Write(p)
+			return written + n, err
+		}
+		n, err := lw.w.Write(p[:i])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if _, err := lw.w.Write(lw.ending); err != nil {
+			return written, err
+		}
+		written++
+		p = p[i+1:]
+	}
+	return written, nil
+}
@@ -0,0 +1,32 @@
+package quadtree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseError(t *testing.T) {
+	err := parseErrorf(3, "unexpected %q", "x")
+	assert.Equal(t, `line 3: unexpected "x"`, err.Error())
+}
+
+func TestWriteOptionsLineEnding(t *testing.T) {
+	var buf bytes.Buffer
+	w := WriteOptions{}.writer(&buf)
+	assert.Equal(t, &buf, w)
+
+	w = WriteOptions{LineEnding: "\r\n"}.writer(&buf)
+	n, err := fmt.Fprint(w, "a\nb\n\nc")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "a\r\nb\r\n\r\nc", buf.String())
+}
+
+func TestPrintWithLineEnding(t *testing.T) {
+	var buf bytes.Buffer
+	EmptyTree(1).PrintWith(PrintOptions{Output: &buf, Dead: ".", WriteOptions: WriteOptions{LineEnding: "\r\n"}})
+	assert.Equal(t, "..\r\n..\r\n", buf.String())
+}
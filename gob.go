@@ -0,0 +1,105 @@
+package quadtree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// gobTree is the serialized form of a tree: its rule and every unique node once. Index 0 is the dead leaf,
+// index 1 the live leaf and node i refers to the indices of its NW, NE, SW and SE childs, which are all lower than i+2.
+type gobTree struct {
+	Rule  string
+	Nodes [][4]uint64
+	Root  uint64
+}
+
+// GobEncode encodes qt with each unique node written once, so regular patterns stay small.
+// Cells with a State other than 0 and 1 can't be encoded.
+func (qt *Quadtree) GobEncode() ([]byte, error) {
+	tree := gobTree{Rule: qt.Rule().String()}
+	index := map[*Quadtree]uint64{qt.space.dead: 0, qt.space.live: 1}
+	var state State
+	var visit func(node *Quadtree) uint64
+	visit = func(node *Quadtree) uint64 {
+		if i, ok := index[node]; ok {
+			return i
+		}
+		if node.IsLeaf() {
+			state = node.state
+			return 0
+		}
+		childs := [4]uint64{visit(node.NW), visit(node.NE), visit(node.SW), visit(node.SE)}
+		i := uint64(len(tree.Nodes)) + 2
+		index[node] = i
+		tree.Nodes = append(tree.Nodes, childs)
+		return i
+	}
+	tree.Root = visit(qt)
+	if state > 1 {
+		return nil, fmt.Errorf("cell state %v isn't supported", state)
+	}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(tree); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// GobDecode rebuilds the tree encoded by GobEncode through NewTree, so its nodes are cached again.
+// qt becomes a copy of the root node: its childs are the cached nodes, but qt itself isn't, so it's never == to
+// the original root. Use DecodeGob for the cached root.
+func (qt *Quadtree) GobDecode(data []byte) error {
+	root, err := DecodeGob(data)
+	if err != nil {
+		return err
+	}
+	qt.assign(root)
+	return nil
+}
+
+// DecodeGob rebuilds the tree encoded by GobEncode through NewTree and returns its root, which is the same node as
+// the encoded root if that is still cached, like every other node of the tree.
+func DecodeGob(data []byte) (*Quadtree, error) {
+	var tree gobTree
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&tree); err != nil {
+		return nil, err
+	}
+	rule, err := ParseRule(tree.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Born[0] {
+		return nil, fmt.Errorf("rule %v with birth on 0 neighbors isn't supported", rule)
+	}
+	s := spaceFor(rule)
+
+	nodes := make([]*Quadtree, 2, len(tree.Nodes)+2)
+	nodes[0], nodes[1] = s.dead, s.live
+	for i, childs := range tree.Nodes {
+		var c [4]*Quadtree
+		for j, ref := range childs {
+			if ref >= uint64(len(nodes)) {
+				return nil, fmt.Errorf("node %v refers to node %v which isn't defined yet", i+2, ref)
+			}
+			c[j] = nodes[ref]
+		}
+		if c[0].Level != c[1].Level || c[0].Level != c[2].Level || c[0].Level != c[3].Level {
+			return nil, fmt.Errorf("node %v has childs of different levels", i+2)
+		}
+		if c[0].Level+1 > MaxLevel {
+			return nil, fmt.Errorf("node %v exceeds the maximum level %v", i+2, MaxLevel)
+		}
+		nodes = append(nodes, NewTree(Childs{NW: c[0], NE: c[1], SW: c[2], SE: c[3]}))
+	}
+	if tree.Root >= uint64(len(nodes)) {
+		return nil, fmt.Errorf("root %v isn't defined", tree.Root)
+	}
+	return nodes[tree.Root], nil
+}
+
+// assign makes qt a copy of node, without its memoized next generation
+func (qt *Quadtree) assign(node *Quadtree) {
+	*qt = Quadtree{Level: node.Level, Childs: node.Childs, Population: node.Population, space: node.space, state: node.state}
+}
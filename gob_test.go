@@ -0,0 +1,85 @@
+package quadtree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGob(t *testing.T) {
+	highLife, _ := ParseRule("B36/S23")
+	for _, qt := range []*Quadtree{glider().GrowToFit(100, -50), EmptyTree(40), LiveLeaf(), blinker().WithRule(highLife)} {
+		var b bytes.Buffer
+		assert.NoError(t, gob.NewEncoder(&b).Encode(qt))
+		var decoded *Quadtree
+		assert.NoError(t, gob.NewDecoder(&b).Decode(&decoded))
+		assert.Equal(t, qt.Level, decoded.Level)
+		assert.Equal(t, qt.Rule(), decoded.Rule())
+		// the childs are the cached nodes, so the root is structurally identical
+		assert.True(t, qt.Childs == decoded.Childs)
+	}
+
+	// a regular pattern of a million cells only needs a node per level
+	tile := NewTree(Childs{DeadLeaf(), DeadLeaf(), LiveLeaf(), DeadLeaf()})
+	for tile.Level < 11 {
+		tile = NewTree(Childs{tile, tile, tile, tile})
+	}
+	data, err := tile.GobEncode()
+	assert.NoError(t, err)
+	assert.True(t, len(data) < 1000, "%v bytes", len(data))
+
+	var qt Quadtree
+	assert.Error(t, qt.GobDecode([]byte("garbage")))
+	_, err = DecodeGob([]byte("garbage"))
+	assert.Error(t, err)
+	for _, tree := range []gobTree{
+		{Rule: "B3/S23", Nodes: [][4]uint64{{0, 1, 2, 0}}, Root: 2},
+		{Rule: "B3/S23", Nodes: [][4]uint64{{0, 1, 1, 0}, {2, 2, 2, 0}}, Root: 3},
+		{Rule: "B3/S23", Nodes: [][4]uint64{{0, 1, 1, 0}}, Root: 3},
+		{Rule: "B3/X", Root: 0},
+		{Rule: "B03/S23", Root: 0},
+	} {
+		var b bytes.Buffer
+		assert.NoError(t, gob.NewEncoder(&b).Encode(tree))
+		assert.Error(t, qt.GobDecode(b.Bytes()), "%v", tree)
+	}
+
+	// a chain of nodes one level above the other, the last one is beyond MaxLevel
+	chain := gobTree{Rule: "B3/S23", Nodes: [][4]uint64{{1, 0, 0, 0}}}
+	for i := uint64(1); i <= uint64(MaxLevel); i++ {
+		chain.Nodes = append(chain.Nodes, [4]uint64{i + 1, i + 1, i + 1, i + 1})
+	}
+	chain.Root = uint64(len(chain.Nodes)) + 1
+	var b bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&b).Encode(chain))
+	_, err = DecodeGob(b.Bytes())
+	assert.Error(t, err)
+
+	chain.Nodes = chain.Nodes[:MaxLevel]
+	chain.Root = uint64(len(chain.Nodes)) + 1
+	b.Reset()
+	assert.NoError(t, gob.NewEncoder(&b).Encode(chain))
+	root, err := DecodeGob(b.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, MaxLevel, root.Level)
+}
+
+func TestDecodeGob(t *testing.T) {
+	for _, qt := range []*Quadtree{glider().GrowToFit(100, -50), EmptyTree(40), LiveLeaf(), DeadLeaf()} {
+		data, err := qt.GobEncode()
+		assert.NoError(t, err)
+		decoded, err := DecodeGob(data)
+		assert.NoError(t, err)
+		assert.True(t, qt == decoded)
+	}
+
+	// the copy made by GobDecode steps to the same nodes
+	qt := glider().GrowToFit(100, -50)
+	data, _ := qt.GobEncode()
+	var copied Quadtree
+	assert.NoError(t, copied.GobDecode(data))
+	assert.False(t, &copied == qt)
+	assert.True(t, copied.NextGen() == qt.NextGen())
+}
@@ -0,0 +1,32 @@
+package quadtree
+
+// History records the roots of successive generations, so a front-end can rewind a simulation.
+// Trees are immutable and share their nodes, so a root per generation costs little more than a pointer.
+// The zero value is an empty History ready to use.
+type History struct {
+	roots []*Quadtree
+}
+
+// Push records qt as the latest generation
+func (h *History) Push(qt *Quadtree) {
+	h.roots = append(h.roots, qt)
+}
+
+// Back rewinds the History by n generations and returns the root that is the latest one afterwards.
+// The n later roots are dropped, so pushing continues from there. ok is false and nothing changes
+// if fewer than n+1 roots were recorded or n is negative.
+func (h *History) Back(n int) (qt *Quadtree, ok bool) {
+	if n < 0 || n >= len(h.roots) {
+		return nil, false
+	}
+	for i := len(h.roots) - n; i < len(h.roots); i++ {
+		h.roots[i] = nil // don't keep the dropped trees alive
+	}
+	h.roots = h.roots[:len(h.roots)-n]
+	return h.roots[len(h.roots)-1], true
+}
+
+// Len returns the number of recorded roots
+func (h *History) Len() int {
+	return len(h.roots)
+}
@@ -0,0 +1,42 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	var h History
+	_, ok := h.Back(0)
+	assert.False(t, ok)
+
+	qt := glider()
+	roots := []*Quadtree{qt}
+	h.Push(qt)
+	for i := 0; i < 4; i++ {
+		qt = qt.expand().NextGen()
+		roots = append(roots, qt)
+		h.Push(qt)
+	}
+	assert.Equal(t, 5, h.Len())
+
+	latest, ok := h.Back(0)
+	assert.True(t, ok)
+	assert.True(t, latest == roots[4])
+
+	_, ok = h.Back(5)
+	assert.False(t, ok)
+	assert.Equal(t, 5, h.Len())
+
+	back, ok := h.Back(3)
+	assert.True(t, ok)
+	assert.True(t, back == roots[1])
+	assert.Equal(t, 2, h.Len())
+
+	// pushing continues from the rewound generation
+	h.Push(roots[3])
+	back, ok = h.Back(1)
+	assert.True(t, ok)
+	assert.True(t, back == roots[1])
+}
@@ -0,0 +1,48 @@
+package quadtree
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// DefaultImageLevel bounds the tree built by FromImage. Images that don't fit are downscaled.
+const DefaultImageLevel = 12
+
+// FromImage returns a tree with a live cell for every pixel of img darker than threshold.
+// The top left pixel of img is placed at the origin. It's FromImageLevel with DefaultImageLevel.
+func FromImage(img image.Image, threshold uint8) *Quadtree {
+	return FromImageLevel(img, threshold, DefaultImageLevel)
+}
+
+// FromImageLevel is FromImage with a tree of at most maxLevel. Images wider or higher than the
+// positive coordinate range of maxLevel are downscaled by sampling every n-th pixel.
+// It panics if maxLevel isn't between 1 and MaxLevel.
+func FromImageLevel(img image.Image, threshold uint8, maxLevel uint) *Quadtree {
+	if maxLevel < 1 || maxLevel > MaxLevel {
+		panic(fmt.Sprintf("FromImageLevel needs a level between 1 and %v, got %v", MaxLevel, maxLevel))
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	limit := 1 << (maxLevel - 1)
+	step := 1
+	for (width-1)/step >= limit || (height-1)/step >= limit {
+		step++
+	}
+
+	qt := EmptyTree(1)
+	if width == 0 || height == 0 {
+		return qt
+	}
+	qt = qt.GrowToFit(Dim((width-1)/step), Dim((height-1)/step))
+	for y := 0; y*step < height; y++ {
+		for x := 0; x*step < width; x++ {
+			pixel := img.At(bounds.Min.X+x*step, bounds.Min.Y+y*step)
+			if color.GrayModel.Convert(pixel).(color.Gray).Y < threshold {
+				qt = qt.SetCell(Dim(x), Dim(y), 1)
+			}
+		}
+	}
+	return qt
+}
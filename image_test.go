@@ -0,0 +1,54 @@
+package quadtree
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 5, 3))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.SetGray(0, 0, color.Gray{0x00})
+	img.SetGray(4, 2, color.Gray{0x40})
+	img.SetGray(2, 1, color.Gray{0x90})
+
+	qt := FromImage(img, 0x80)
+	assert.Equal(t, Dim(2), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(0, 0))
+	assert.Equal(t, Dim(1), qt.Cell(4, 2))
+	assert.Equal(t, Dim(0), qt.Cell(2, 1))
+}
+
+func TestFromImageDownscale(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	img.SetGray(8, 8, color.Gray{0xff})
+	qt := FromImageLevel(img, 0x80, 4)
+	assert.True(t, qt.Level <= 4)
+	// every second pixel is sampled, so the bright pixel leaves a dead cell at (4,4)
+	assert.Equal(t, Dim(0), qt.Cell(4, 4))
+	assert.Equal(t, Dim(63), qt.Population)
+}
+
+func TestFromImageLevelBorder(t *testing.T) {
+	// 8 pixels fit the positive range of level 4, 17 don't fit with every second one
+	for _, width := range []int{8, 9, 16, 17, 24, 25} {
+		img := image.NewGray(image.Rect(0, 0, width, 1))
+		qt := FromImageLevel(img, 0x80, 4)
+		assert.Equal(t, uint(4), qt.Level, "width %v", width)
+		assert.True(t, qt.Population > 0)
+	}
+}
+
+func TestFromImageLevelInvalid(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	for _, level := range []uint{0, MaxLevel + 1, 65, 100} {
+		assert.Panics(t, func() { FromImageLevel(img, 0x80, level) }, "level %v", level)
+	}
+	assert.Equal(t, uint(1), FromImageLevel(img, 0x80, 1).Level)
+	assert.NotPanics(t, func() { FromImageLevel(img, 0x80, MaxLevel) })
+}
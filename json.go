@@ -0,0 +1,105 @@
+package quadtree
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxJSONCells is the limit on live cells MarshalJSON writes and UnmarshalJSON reads. Coordinate lists are meant
+// for front-ends showing small patterns, bigger ones should be exported as RLE or macrocell.
+// EncodeJSON and DecodeJSON take other limits.
+const MaxJSONCells Dim = 1000000
+
+// jsonTree is the JSON form of a tree: its live cells as [x, y] pairs in reading order and its level.
+// The rule is left out for Conway's rule. States holds the state of each cell and is left out if all are 1.
+type jsonTree struct {
+	Cells  [][2]Dim `json:"cells"`
+	States []int    `json:"states,omitempty"`
+	Level  uint     `json:"level"`
+	Rule   string   `json:"rule,omitempty"`
+}
+
+// MarshalJSON encodes qt as {"cells":[[x,y],...],"level":L}. It fails if qt has more than MaxJSONCells live cells.
+func (qt *Quadtree) MarshalJSON() ([]byte, error) {
+	return qt.EncodeJSON(MaxJSONCells)
+}
+
+// EncodeJSON encodes qt like MarshalJSON, but fails only if qt has more than maxCells live cells.
+func (qt *Quadtree) EncodeJSON(maxCells Dim) ([]byte, error) {
+	if qt.Population > maxCells {
+		return nil, fmt.Errorf("%v live cells exceed the limit of %v for JSON", qt.Population, maxCells)
+	}
+	tree := jsonTree{Cells: make([][2]Dim, 0, qt.Population), Level: qt.Level}
+	if qt.space != conwaySpace {
+		tree.Rule = qt.Rule().String()
+	}
+	states := make([]int, 0, qt.Population)
+	multistate := false
+	for _, p := range qt.livePoints() {
+		tree.Cells = append(tree.Cells, [2]Dim{p.x, p.y})
+		state := qt.CellState(p.x, p.y)
+		states = append(states, int(state))
+		multistate = multistate || state != 1
+	}
+	if multistate {
+		tree.States = states
+	}
+	return json.Marshal(tree)
+}
+
+// UnmarshalJSON rebuilds the tree encoded by MarshalJSON like DecodeJSON with a limit of MaxJSONCells.
+// qt becomes a copy of the root node, its childs are the cached nodes. Use DecodeJSON for the cached root.
+func (qt *Quadtree) UnmarshalJSON(data []byte) error {
+	root, err := DecodeJSON(data, MaxJSONCells)
+	if err != nil {
+		return err
+	}
+	qt.assign(root)
+	return nil
+}
+
+// DecodeJSON rebuilds the tree encoded by MarshalJSON cell by cell, growing it like SetCellGrow, starting with an
+// empty tree of the encoded level. It returns the root, which like any tree built by SetCell is the cached node
+// if it can be cached. It fails if data lists more than maxCells cells.
+func DecodeJSON(data []byte, maxCells Dim) (*Quadtree, error) {
+	var tree jsonTree
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	if tree.Level < 1 || tree.Level > MaxLevel {
+		return nil, fmt.Errorf("invalid level %v", tree.Level)
+	}
+	if Dim(len(tree.Cells)) > maxCells {
+		return nil, fmt.Errorf("%v cells exceed the limit of %v for JSON", len(tree.Cells), maxCells)
+	}
+	if tree.States != nil && len(tree.States) != len(tree.Cells) {
+		return nil, fmt.Errorf("%v states given for %v cells", len(tree.States), len(tree.Cells))
+	}
+	s := conwaySpace
+	if tree.Rule != "" {
+		rule, err := ParseRule(tree.Rule)
+		if err != nil {
+			return nil, err
+		}
+		if rule.Born[0] {
+			return nil, fmt.Errorf("rule %v with birth on 0 neighbors isn't supported", rule)
+		}
+		s = spaceFor(rule)
+	}
+
+	decoded := s.emptyTree(tree.Level)
+	for i, c := range tree.Cells {
+		if !levelContains(MaxLevel, c[0], c[1]) {
+			return nil, fmt.Errorf("cell (%v, %v) lies beyond the coordinate range of the biggest tree", c[0], c[1])
+		}
+		state := State(1)
+		if tree.States != nil {
+			if tree.States[i] < 0 || tree.States[i] > MaxState {
+				return nil, fmt.Errorf("invalid state %v of cell (%v, %v)", tree.States[i], c[0], c[1])
+			}
+			state = State(tree.States[i])
+		}
+		decoded = decoded.GrowToFit(c[0], c[1]).SetCellState(c[0], c[1], state)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,77 @@
+package quadtree
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(blinker())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"cells":[[-1,0],[0,0],[1,0]],"level":3}`, string(data))
+
+	data, err = json.Marshal(EmptyTree(2))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"cells":[],"level":2}`, string(data))
+
+	_, err = blinker().EncodeJSON(2)
+	assert.Error(t, err)
+	data, err = blinker().EncodeJSON(3)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"cells":[[-1,0],[0,0],[1,0]],"level":3}`, string(data))
+
+	// states other than 1 are listed per cell
+	data, err = json.Marshal(blinker().SetCellState(1, 0, 2))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"cells":[[-1,0],[0,0],[1,0]],"states":[1,1,2],"level":3}`, string(data))
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	highLife, _ := ParseRule("B36/S23")
+	for _, qt := range []*Quadtree{blinker(), glider().GrowToFit(100, -50), EmptyTree(5), blinker().WithRule(highLife)} {
+		data, err := json.Marshal(qt)
+		assert.NoError(t, err)
+		var decoded *Quadtree
+		assert.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, qt.Level, decoded.Level)
+		assert.Equal(t, qt.Rule(), decoded.Rule())
+		assert.True(t, qt.Childs == decoded.Childs)
+
+		root, err := DecodeJSON(data, MaxJSONCells)
+		assert.NoError(t, err)
+		assert.True(t, qt == root)
+	}
+
+	// multistate cells round trip
+	multi := glider().GrowToFit(-3, 2).SetCellState(0, 0, 3).SetCellState(-3, 2, MaxState)
+	data, err := json.Marshal(multi)
+	assert.NoError(t, err)
+	root, err := DecodeJSON(data, MaxJSONCells)
+	assert.NoError(t, err)
+	assert.True(t, multi == root)
+	assert.Equal(t, State(MaxState), root.CellState(-3, 2))
+
+	// cells beyond the level grow the tree
+	var qt Quadtree
+	assert.NoError(t, json.Unmarshal([]byte(`{"cells":[[0,0],[100,-3]],"level":2}`), &qt))
+	assert.Equal(t, uint(8), qt.Level)
+	assert.Equal(t, Dim(1), qt.Cell(100, -3))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"cells":[],"level":0}`), &qt))
+	assert.Error(t, json.Unmarshal([]byte(`{"cells":[[0,0]],"level":2,"rule":"B0/S"}`), &qt))
+	assert.Error(t, json.Unmarshal([]byte(`{"cells":[[4611686018427387904,0]],"level":2}`), &qt))
+	assert.Error(t, json.Unmarshal([]byte(`{"cells":[["a",2]],"level":2}`), &qt))
+	assert.Error(t, json.Unmarshal([]byte(`{"cells":[[0,0]],"states":[1,2],"level":2}`), &qt))
+	assert.Error(t, json.Unmarshal([]byte(`{"cells":[[0,0]],"states":[256],"level":2}`), &qt))
+	assert.Error(t, json.Unmarshal([]byte(`{"cells":[[0,0]],"states":[-1],"level":2}`), &qt))
+
+	// the limit applies to decoding too
+	data = []byte(`{"cells":[[0,0],[1,0],[2,0]],"level":2}`)
+	_, err = DecodeJSON(data, 2)
+	assert.Error(t, err)
+	root, err = DecodeJSON(data, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, Dim(3), root.Population)
+}
@@ -0,0 +1,61 @@
+package quadtree
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const life106Glider = `#Life 1.06
+0 -1
+
+1 0
+# the last row
+-1 1
+0 1
+1 1
+`
+
+func TestLoadLife106(t *testing.T) {
+	qt, err := LoadLife106(strings.NewReader(life106Glider))
+	assert.NoError(t, err)
+	assert.Equal(t, glider().livePoints(), qt.livePoints())
+
+	// spread out cells grow the tree
+	qt, err = LoadLife106(strings.NewReader("#Life 1.06\n-1000 5\n70000 -3\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Dim(2), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(70000, -3))
+
+	for _, c := range []struct {
+		input string
+		line  int
+	}{
+		{"#Life 1.06\n1\n", 2},
+		{"#Life 1.06\n1 2 3\n", 2},
+		{"0 0\na 0\n", 2},
+		{"0 0\n\n0 1.5\n", 3},
+		{"0 0\n9223372036854775807 0\n", 2},
+		{"0 0\n0 -9223372036854775808\n", 2},
+	} {
+		_, err := LoadLife106(strings.NewReader(c.input))
+		if assert.Error(t, err, c.input) {
+			assert.Equal(t, c.line, err.(*ParseError).Line, c.input)
+		}
+	}
+}
+
+func TestExportLife106(t *testing.T) {
+	var b strings.Builder
+	assert.NoError(t, glider().ExportLife106(&b))
+	assert.Equal(t, "#Life 1.06\n0 -1\n1 0\n-1 1\n0 1\n1 1\n", b.String())
+
+	qt, err := LoadLife106(strings.NewReader(b.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, glider().livePoints(), qt.livePoints())
+
+	b.Reset()
+	assert.NoError(t, EmptyTree(2).ExportLife106With(&b, WriteOptions{LineEnding: "\r\n"}))
+	assert.Equal(t, "#Life 1.06\r\n", b.String())
+}
@@ -0,0 +1,189 @@
+package quadtree
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LoadMacrocell reads a pattern in Golly's macrocell ([M2]) format.
+// The input is read line by line and every node is built through NewTree as soon as its line is read,
+// so only the deduplicated DAG is ever held in memory, never the expanded pattern.
+// Node lines may only reference nodes defined on earlier lines. The last node becomes the root, centered at the origin.
+// A "#R" line before the first node sets the rule, Conway's Life is the default.
+func LoadMacrocell(r io.Reader) (*Quadtree, error) {
+	scanner := bufio.NewScanner(r)
+	nodes := []*Quadtree{nil} // node ids start with 1, 0 denotes an empty node
+	space := conwaySpace
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNumber == 1 {
+			if !strings.HasPrefix(line, "[M2]") {
+				return nil, parseErrorf(lineNumber, "missing [M2] header")
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#R") {
+			if len(nodes) > 1 {
+				return nil, parseErrorf(lineNumber, "rule after the first node")
+			}
+			rule, err := ParseRule(strings.TrimSpace(line[2:]))
+			if err != nil {
+				return nil, parseErrorf(lineNumber, "%v", err)
+			}
+			if rule.Born[0] {
+				return nil, parseErrorf(lineNumber, "rule %v with birth on 0 neighbors isn't supported", rule)
+			}
+			space = spaceFor(rule)
+			continue
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var node *Quadtree
+		var err error
+		if line[0] == '.' || line[0] == '*' || line[0] == '$' {
+			node, err = parseMacrocellLeaf(line, space)
+		} else {
+			node, err = parseMacrocellNode(line, nodes, space)
+		}
+		if err != nil {
+			return nil, parseErrorf(lineNumber, "%v", err)
+		}
+		nodes = append(nodes, node)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(nodes) == 1 {
+		return nil, parseErrorf(lineNumber, "no nodes defined")
+	}
+	return nodes[len(nodes)-1], nil
+}
+
+// parseMacrocellLeaf builds a level 3 node of space s from an 8x8 bitmap line like "$.*$..*$***$"
+func parseMacrocellLeaf(line string, s *ruleSpace) (*Quadtree, error) {
+	qt := s.emptyTree(3)
+	x, y := Dim(0), Dim(0)
+	for _, c := range line {
+		switch c {
+		case '.':
+			x++
+		case '*':
+			if x > 7 || y > 7 {
+				return nil, fmt.Errorf("cell outside of 8x8 leaf")
+			}
+			qt = qt.SetCell(x-4, y-4, 1)
+			x++
+		case '$':
+			x = 0
+			y++
+		default:
+			return nil, fmt.Errorf("unexpected character %q in leaf", c)
+		}
+	}
+	return qt, nil
+}
+
+// parseMacrocellNode builds a node of space s from a line "level nw ne sw se" referencing already defined nodes
+func parseMacrocellNode(line string, nodes []*Quadtree, s *ruleSpace) (*Quadtree, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected level and four child ids, got %q", line)
+	}
+	level, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil || level < 4 || level > uint64(MaxLevel) {
+		return nil, fmt.Errorf("invalid level %q", fields[0])
+	}
+	var childs [4]*Quadtree
+	for i, field := range fields[1:] {
+		id, err := strconv.Atoi(field)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("invalid node id %q", field)
+		}
+		if id >= len(nodes) {
+			return nil, fmt.Errorf("reference to node %v which isn't defined yet, the last node is %v", id, len(nodes)-1)
+		}
+		child := nodes[id]
+		if id == 0 {
+			child = s.emptyTree(uint(level) - 1)
+		}
+		if child.Level != uint(level)-1 {
+			return nil, fmt.Errorf("node %v has level %v, expected %v", id, child.Level, level-1)
+		}
+		childs[i] = child
+	}
+	return NewTree(Childs{NW: childs[0], NE: childs[1], SW: childs[2], SE: childs[3]}), nil
+}
+
+// ExportMacrocell writes qt in Golly's macrocell ([M2]) format. See ExportMacrocellWith.
+func (qt *Quadtree) ExportMacrocell(w io.Writer) error {
+	return qt.ExportMacrocellWith(w, WriteOptions{})
+}
+
+// ExportMacrocellWith writes qt in Golly's macrocell ([M2]) format: every unique non empty node on its own line after
+// its childs, level 3 nodes as 8x8 bitmaps. Nodes are deduplicated by identity, so the output follows the sharing of the cache.
+func (qt *Quadtree) ExportMacrocellWith(w io.Writer, opts WriteOptions) error {
+	for qt.Level < 3 {
+		qt = qt.grow()
+	}
+	bw := bufio.NewWriter(opts.writer(w))
+	fmt.Fprintf(bw, "[M2] (noctilu/quadtree)\n#R %v\n", qt.Rule())
+	if qt.IsEmpty() {
+		// a single node with empty childs keeps the level, a level 3 root is an empty leaf
+		if qt.Level == 3 {
+			bw.WriteString("$\n")
+		} else {
+			fmt.Fprintf(bw, "%d 0 0 0 0\n", qt.Level)
+		}
+		return bw.Flush()
+	}
+
+	ids := make(map[*Quadtree]int)
+	var write func(node *Quadtree) int
+	write = func(node *Quadtree) int {
+		if node.IsEmpty() {
+			return 0
+		}
+		if id, ok := ids[node]; ok {
+			return id
+		}
+		if node.Level == 3 {
+			writeMacrocellLeaf(bw, node)
+		} else {
+			nw, ne, sw, se := write(node.NW), write(node.NE), write(node.SW), write(node.SE)
+			fmt.Fprintf(bw, "%d %d %d %d %d\n", node.Level, nw, ne, sw, se)
+		}
+		id := len(ids) + 1
+		ids[node] = id
+		return id
+	}
+	write(qt)
+	return bw.Flush()
+}
+
+// writeMacrocellLeaf writes the level 3 node qt as 8x8 bitmap line, leaving out trailing dead cells and rows
+func writeMacrocellLeaf(w *bufio.Writer, qt *Quadtree) {
+	var line []byte
+	for y := Dim(-4); y < 4; y++ {
+		dead := 0
+		for x := Dim(-4); x < 4; x++ {
+			if qt.Cell(x, y) == 0 {
+				dead++
+				continue
+			}
+			line = append(line, strings.Repeat(".", dead)...)
+			line = append(line, '*')
+			dead = 0
+		}
+		line = append(line, '$')
+	}
+	w.Write(bytes.TrimRight(line, "$"))
+	w.WriteString("$\n")
+}
@@ -0,0 +1,133 @@
+package quadtree
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// glider in the north west corner of an 8x8 leaf
+const macrocellGlider = "[M2] (golly 3.0)\n#R B3/S23\n.*$..*$***$\n"
+
+func TestLoadMacrocellLeaf(t *testing.T) {
+	qt, err := LoadMacrocell(strings.NewReader(macrocellGlider))
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), qt.Level)
+	assert.Equal(t, Dim(5), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(-3, -4))
+	assert.Equal(t, Dim(1), qt.Cell(-2, -3))
+	assert.Equal(t, Dim(1), qt.Cell(-4, -2))
+	assert.Equal(t, Dim(1), qt.Cell(-3, -2))
+	assert.Equal(t, Dim(1), qt.Cell(-2, -2))
+}
+
+func TestLoadMacrocellNodes(t *testing.T) {
+	input := macrocellGlider + "4 0 1 0 0\n5 2 0 0 2\n"
+	qt, err := LoadMacrocell(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, uint(5), qt.Level)
+	assert.Equal(t, Dim(10), qt.Population)
+	// the level 4 node in the north west has the leaf in its north east
+	assert.Equal(t, Dim(1), qt.Cell(-16+8+1, -16))
+	assert.Equal(t, Dim(1), qt.Cell(8+1, 0))
+	assert.Equal(t, Dim(0), qt.Cell(-16+1, 0))
+}
+
+func TestLoadMacrocellLarge(t *testing.T) {
+	// each level doubles the pattern along the diagonal, expanded this would be 2^60 x 2^60 cells
+	var b strings.Builder
+	b.WriteString(macrocellGlider)
+	b.WriteString("4 1 0 0 1\n")
+	for level := 5; level <= 60; level++ {
+		fmt.Fprintf(&b, "%d %d 0 0 %d\n", level, level-3, level-3)
+	}
+	qt, err := LoadMacrocell(strings.NewReader(b.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, uint(60), qt.Level)
+	assert.Equal(t, Dim(5)<<57, qt.Population)
+
+	nodes := make(map[*Quadtree]struct{})
+	qt.collectNodes(nodes)
+	// one pattern node and one empty node per level plus the nodes of the leaf
+	assert.True(t, len(nodes) < 2*60+20)
+}
+
+func TestLoadMacrocellErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"#R B3/S23\n",
+		"[M2]\n",
+		"[M2]\n.*$..*x$\n",
+		"[M2]\n.........*\n",
+		"[M2]\n.*$\n4 1 0 0\n",
+		"[M2]\n.*$\n4 2 0 0 0\n",
+		"[M2]\n.*$\n5 1 0 0 0\n",
+		"[M2]\n.*$\n4 1 a 0 0\n",
+		"[M2]\n.*$\nx 1 0 0 0\n",
+		"[M2]\n#R B3/S2x\n.*$\n",
+		"[M2]\n#R B03/S23\n.*$\n",
+		"[M2]\n.*$\n#R B36/S23\n",
+	} {
+		_, err := LoadMacrocell(strings.NewReader(input))
+		assert.Error(t, err, input)
+		if err != nil && input != "" {
+			assert.IsType(t, &ParseError{}, err, input)
+		}
+	}
+
+	_, err := LoadMacrocell(strings.NewReader("[M2]\n.*$\n\n4 0 2 0 0\n"))
+	assert.Equal(t, &ParseError{4, "reference to node 2 which isn't defined yet, the last node is 1"}, err)
+}
+
+func TestExportMacrocell(t *testing.T) {
+	qt, err := LoadMacrocell(strings.NewReader(macrocellGlider + "4 0 1 0 0\n5 2 0 0 2\n"))
+	assert.NoError(t, err)
+	var b strings.Builder
+	assert.NoError(t, qt.ExportMacrocell(&b))
+	// the shared level 4 node is written once
+	assert.Equal(t, "[M2] (noctilu/quadtree)\n#R B3/S23\n.*$..*$***$\n4 0 1 0 0\n5 2 0 0 2\n", b.String())
+
+	read, err := LoadMacrocell(strings.NewReader(b.String()))
+	assert.NoError(t, err)
+	assert.True(t, qt == read)
+
+	b.Reset()
+	assert.NoError(t, EmptyTree(5).ExportMacrocellWith(&b, WriteOptions{LineEnding: "\r\n"}))
+	assert.Equal(t, "[M2] (noctilu/quadtree)\r\n#R B3/S23\r\n5 0 0 0 0\r\n", b.String())
+}
+
+func TestExportMacrocellEmpty(t *testing.T) {
+	for _, level := range []uint{3, 4, 20} {
+		var b strings.Builder
+		assert.NoError(t, EmptyTree(level).ExportMacrocell(&b))
+		read, err := LoadMacrocell(strings.NewReader(b.String()))
+		assert.NoError(t, err)
+		assert.True(t, EmptyTree(level) == read, "level %v", level)
+	}
+}
+
+func TestExportMacrocellRoundTrip(t *testing.T) {
+	qt := EmptyTree(1).SetCells(randomCells(300, 100))
+	var b strings.Builder
+	assert.NoError(t, qt.ExportMacrocell(&b))
+	read, err := LoadMacrocell(strings.NewReader(b.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, qt.Level, read.Level)
+	assert.Equal(t, qt.livePoints(), read.livePoints())
+}
+
+func TestLoadMacrocellRule(t *testing.T) {
+	highLife, err := ParseRule("B36/S23")
+	assert.NoError(t, err)
+	qt := EmptyTree(4).WithRule(highLife).SetCells(randomCells(50, 16))
+	var b strings.Builder
+	assert.NoError(t, qt.ExportMacrocell(&b))
+	assert.True(t, strings.HasPrefix(b.String(), "[M2] (noctilu/quadtree)\n#R B36/S23\n"))
+
+	read, err := LoadMacrocell(strings.NewReader(b.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, highLife, read.Rule())
+	assert.True(t, qt == read)
+}
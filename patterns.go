@@ -0,0 +1,53 @@
+package quadtree
+
+import "strings"
+
+// pattern returns the smallest tree under Conway's rule with the given live cells
+func pattern(cells ...[2]Dim) *Quadtree {
+	set := make([]struct{ X, Y, V Dim }, len(cells))
+	for i, c := range cells {
+		set[i].X, set[i].Y, set[i].V = c[0], c[1], 1
+	}
+	return EmptyTree(1).SetCells(set)
+}
+
+// Block returns the still life with live cells (-1,-1), (0,-1), (-1,0) and (0,0)
+func Block() *Quadtree {
+	return pattern([2]Dim{-1, -1}, [2]Dim{0, -1}, [2]Dim{-1, 0}, [2]Dim{0, 0})
+}
+
+// Blinker returns the horizontal phase of the period 2 oscillator with live cells (-1,0), (0,0) and (1,0)
+func Blinker() *Quadtree {
+	return pattern([2]Dim{-1, 0}, [2]Dim{0, 0}, [2]Dim{1, 0})
+}
+
+// Glider returns a glider with live cells (0,-1), (1,0), (-1,1), (0,1) and (1,1).
+// It moves by (1,1), south east, every 4 generations.
+func Glider() *Quadtree {
+	return pattern([2]Dim{0, -1}, [2]Dim{1, 0}, [2]Dim{-1, 1}, [2]Dim{0, 1}, [2]Dim{1, 1})
+}
+
+// LWSS returns a lightweight spaceship with live cells (-1,-2), (2,-2), (-2,-1), (-2,0), (2,0), (-2,1), (-1,1), (0,1)
+// and (1,1). It moves by (-2,0), west, every 4 generations.
+func LWSS() *Quadtree {
+	return pattern(
+		[2]Dim{-1, -2}, [2]Dim{2, -2},
+		[2]Dim{-2, -1},
+		[2]Dim{-2, 0}, [2]Dim{2, 0},
+		[2]Dim{-2, 1}, [2]Dim{-1, 1}, [2]Dim{0, 1}, [2]Dim{1, 1},
+	)
+}
+
+// gosperGliderGun is the RLE of Bill Gosper's glider gun
+const gosperGliderGun = `x = 36, y = 9, rule = B3/S23
+24bo$22bobo$12b2o6b2o12b2o$11bo3bo4b2o12b2o$2o8bo5bo3b2o$2o8bo3bob2o4bobo$10bo5bo7bo$11bo3bo$12b2o!`
+
+// GosperGliderGun returns Bill Gosper's glider gun, 36 cells in a 36x9 box from (-18,-4) to (17,4).
+// It has period 30 and emits a glider moving south east every period, the first in generation 15.
+func GosperGliderGun() *Quadtree {
+	qt, err := LoadRLE(strings.NewReader(gosperGliderGun))
+	if err != nil {
+		panic(err)
+	}
+	return qt
+}
@@ -0,0 +1,47 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertMoves asserts that qt reappears moved by (dx,dy) after period generations, but not earlier at the same place
+func assertMoves(t *testing.T, qt *Quadtree, period int, dx, dy Dim) {
+	t.Helper()
+	expect := qt.Translate(dx, dy).livePoints()
+	start := qt.livePoints()
+	qt = qt.GrowToFit(64, 64)
+	for gen := 1; gen < period; gen++ {
+		qt = qt.NextGen()
+		assert.NotEqual(t, start, qt.livePoints(), "generation %v", gen)
+	}
+	assert.Equal(t, expect, qt.NextGen().livePoints())
+}
+
+func TestPatterns(t *testing.T) {
+	assertMoves(t, Block(), 1, 0, 0)
+	assertMoves(t, Blinker(), 2, 0, 0)
+	assertMoves(t, Glider(), 4, 1, 1)
+	assertMoves(t, LWSS(), 4, -2, 0)
+	assert.Equal(t, blinker().livePoints(), Blinker().livePoints())
+	assert.Equal(t, Dim(9), LWSS().Population)
+}
+
+func TestGosperGliderGun(t *testing.T) {
+	gun := GosperGliderGun()
+	assert.Equal(t, Dim(36), gun.Population)
+	box, _ := gun.boundingBox()
+	assert.Equal(t, Rect{-18, -4, 17, 4}, box)
+
+	// every period adds a glider of 5 cells, the gun itself repeats
+	qt := gun.GrowToFit(256, 256)
+	for gen := 1; gen <= 60; gen++ {
+		qt = qt.NextGen()
+	}
+	assert.Equal(t, gun.Population+10, qt.Population)
+	window := func(qt *Quadtree) *Quadtree {
+		return qt.ClearRegion(box.MaxX+1, box.MinY, 255, 255).ClearRegion(box.MinX, box.MaxY+1, 255, 255)
+	}
+	assert.Equal(t, gun.livePoints(), window(qt).livePoints())
+}
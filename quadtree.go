@@ -1,4 +1,5 @@
-/*Package quadtree implements a quadtree with Game of Life's hashlife algorithm. The quadtree divides space in the following 4 sub-quadtrees:
+/*
+Package quadtree implements a quadtree with Game of Life's hashlife algorithm. The quadtree divides space in the following 4 sub-quadtrees:
 
 	NW|NE
 	-----
@@ -8,96 +9,354 @@ A Quadtree of level l covers an area with side length 2^l. A node with level 0 i
 Permitted coordinates are x and y in the range of [- 2^(l-1), 2^(l-1)-1]
 Table with first levels
 
-  level  x & y range  side edge Length
+	level  x & y range  side edge Length
 	-------------------------------------
-	0      [0 , 0]			   1
-	1      [-1, 0]             2
-	2      [-2, 1]             4
-	3      [-4, 3]			   8
-	4      [-8, 7] 			  16
-	5      [-16, 15]          32
-
+	0      [0 , 0]      1
+	1      [-1, 0]      2
+	2      [-2, 1]      4
+	3      [-4, 3]      8
+	4      [-8, 7]      16
+	5      [-16, 15]    32
 
 quadtree instances are immutable. Each change can return another instance. All instances are cached with their childs as hash value.
-Only two leaf nodes exist in memory: one life and one dead node.
+Only one leaf node per State exists in memory per rule, the dead and the live node among them. Trees start out with Conway's rule, WithRule switches them to another one.
 
 The hashlife algorithm is inspired by this article: http://www.drdobbs.com/jvm/an-algorithm-for-compressing-space-and-t/184406478
-Only the 'space compression' and no 'time compression' is implemented
-
+NextGeneration advances one generation at a time, NextGenerationStep adds the 'time compression' and jumps 2^step generations
 */
 package quadtree
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"runtime"
+	"io"
+	"math/big"
+	"os"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 // Dim is the datatype use for the coordinates of the quadtree
 type Dim = int64
 
+// MaxLevel is the highest level a tree can grow to, one less than the bit width of Dim.
+// Coordinates range from -2^(MaxLevel-1) to 2^(MaxLevel-1)-1, so adding the size of a subtree never overflows Dim.
+const MaxLevel = uint(8*unsafe.Sizeof(Dim(0))) - 1
+
 // Childs contains all sub-quadtrees
 type Childs struct {
 	SE, SW, NW, NE *Quadtree
 }
 
+// MaxPopulation is the largest Population of a node. Nodes with more live cells, which only fully populated trees
+// above level 31 can have, saturate at MaxPopulation. PopulationBig counts them exactly.
+const MaxPopulation = Dim(1<<MaxLevel - 1)
+
+// population returns the sum of the populations of the childs, saturated at MaxPopulation
 func (ch *Childs) population() Dim {
-	return ch.SE.Population + ch.SW.Population + ch.NW.Population + ch.NE.Population
+	population := Dim(0)
+	for _, child := range [4]*Quadtree{ch.SE, ch.SW, ch.NW, ch.NE} {
+		if population > MaxPopulation-child.Population {
+			return MaxPopulation
+		}
+		population += child.Population
+	}
+	return population
+}
+
+// PopulationBig returns the exact number of live cells of qt, even if Population saturated at MaxPopulation.
+// Only saturated nodes are descended, each of them once.
+func (qt *Quadtree) PopulationBig() *big.Int {
+	return new(big.Int).Set(qt.populationBig(make(map[*Quadtree]*big.Int)))
+}
+
+func (qt *Quadtree) populationBig(memo map[*Quadtree]*big.Int) *big.Int {
+	if qt.Population < MaxPopulation {
+		return big.NewInt(qt.Population)
+	}
+	if population, ok := memo[qt]; ok {
+		return population
+	}
+	population := new(big.Int)
+	for _, child := range qt.childs() {
+		population.Add(population, child.populationBig(memo))
+	}
+	memo[qt] = population
+	return population
 }
 
 // Quadtree represents one node and consists itself of quadtrees
 type Quadtree struct {
-	Level      uint // distance from leaf layer.
-	Childs          //
-	Population Dim
-	next       *Quadtree // next generation (quadtree half of the size)
+	Level      uint                     // distance from leaf layer.
+	Childs                              //
+	Population Dim                      // never changes after construction, so it can be read while another goroutine steps. See MaxPopulation.
+	next       atomic.Pointer[Quadtree] // next generation (quadtree half of the size)
+	space      *ruleSpace               // rule of the tree, shared by all of its nodes
+	used       uint32                   // cacheEpoch when qt was last returned by NewTree, accessed atomically
+	state      State                    // state of a leaf, 0 for inner nodes
 }
 
 var (
-	liveLeaf = &Quadtree{Population: 1}
-	deadLeaf = &Quadtree{Population: 0}
+	liveLeaf = conwaySpace.live
+	deadLeaf = conwaySpace.dead
 )
 
-// NodeMap is the cache for quadtrees.
+// LiveLeaf returns the leaf node of a live cell under Conway's rule, e.g. to build level 1 nodes with NewTree
+func LiveLeaf() *Quadtree {
+	return liveLeaf
+}
+
+// DeadLeaf returns the leaf node of a dead cell under Conway's rule
+func DeadLeaf() *Quadtree {
+	return deadLeaf
+}
+
+// NodeCache stores the canonical quadtree for each combination of childs. NewTree looks up and inserts nodes through it.
+// Get may be called from several goroutines at once, but never concurrently with Put.
+type NodeCache interface {
+	Get(childs Childs) (*Quadtree, bool)
+	Put(childs Childs, qt *Quadtree)
+}
+
+// NodeMap is the default cache for quadtrees.
 type NodeMap map[Childs]*Quadtree
 
+// Get returns the cached quadtree for childs
+func (m NodeMap) Get(childs Childs) (*Quadtree, bool) {
+	qt, ok := m[childs]
+	return qt, ok
+}
+
+// Put adds qt to the cache
+func (m NodeMap) Put(childs Childs, qt *Quadtree) {
+	m[childs] = qt
+}
+
 var (
-	nodeMap   = make(NodeMap)
-	cacheHit  uint
-	cacheMiss uint
+	nodeMap                = make(NodeMap)
+	nodeCache    NodeCache = nodeMap
+	cacheHit     atomic.Uint64
+	cacheMiss    atomic.Uint64
+	cacheEvicted atomic.Uint64
+	cacheFrozen  atomic.Bool
+	cacheEpoch   atomic.Uint32 // counts the calls of NextGen, the clock of the least recently used eviction
 )
 
-//NewTree returns a tree defined by its childs. Either an instance from cache or a new one using the supplied childs.
+// cacheMutex guards nodeMap, nodeCache, emptyTrees and fullTrees, so trees can be built from several goroutines.
+// Lookups only take the read lock. It's always acquired after mutex, never the other way round.
+var cacheMutex sync.RWMutex
+
+// cacheGet looks up childs in the node cache under the read lock
+func cacheGet(childs Childs) (*Quadtree, bool) {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return nodeCache.Get(childs)
+}
+
+// resetCache replaces the node cache and drops everything derived from it. mutex has to be held.
+func resetCache(cache NodeCache) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	nodeCache = cache
+	nodeMap, _ = cache.(NodeMap)
+	emptyTrees, fullTrees = nil, nil
+	stepCache = make(map[stepKey]*Quadtree)
+}
+
+// SetNodeCache replaces the node cache, nil restores an empty default cache.
+// Nodes built with the old cache aren't known to the new one. Statistics, compaction and the automatic eviction in NextGen
+// are only available for a NodeMap, custom caches have to bound their memory themselves.
+func SetNodeCache(cache NodeCache) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if cache == nil {
+		cache = make(NodeMap)
+	}
+	resetCache(cache)
+	seedPinned()
+}
+
+// pinned counts the Pin calls per tree. It's guarded by mutex.
+var pinned = make(map[*Quadtree]int)
+
+// Pin protects the nodes of qt from the cache eviction in NextGen and from CompactCache until Unpin has been
+// called as often as Pin. When SetNodeCache replaces the cache, the nodes of pinned trees are put into the new one,
+// so trees built later keep sharing them. Use it for snapshots like the roots of a History.
+func Pin(qt *Quadtree) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	pinned[qt]++
+}
+
+// Unpin undoes one call of Pin for qt
+func Unpin(qt *Quadtree) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if pinned[qt] <= 1 {
+		delete(pinned, qt)
+		return
+	}
+	pinned[qt]--
+}
+
+// pinnedNodes adds the nodes of all pinned trees to nodes. mutex has to be held.
+func pinnedNodes(nodes map[*Quadtree]struct{}) {
+	for qt := range pinned {
+		qt.collectNodes(nodes)
+	}
+}
+
+// seedPinned puts the nodes of pinned trees that the cache doesn't know yet into it. mutex has to be held.
+func seedPinned() {
+	nodes := make(map[*Quadtree]struct{})
+	pinnedNodes(nodes)
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	for qt := range nodes {
+		if qt.IsLeaf() || !qt.cacheable() {
+			continue
+		}
+		if _, ok := nodeCache.Get(qt.Childs); !ok {
+			nodeCache.Put(qt.Childs, qt)
+		}
+	}
+}
+
+// isCached reports whether qt is the node stored in the cache for its childs
+func (qt *Quadtree) isCached() bool {
+	cached, ok := cacheGet(qt.Childs)
+	return ok && cached == qt
+}
+
+// NewTree returns a tree defined by its childs. Either an instance from cache or a new one using the supplied childs.
+// All childs have to belong to the same rule. It's safe to call from several goroutines, also while others step trees.
 func NewTree(childs Childs) *Quadtree {
-	qt, ok := nodeMap[childs]
+	qt, ok := cacheGet(childs)
 	if ok {
-		cacheHit++
+		cacheHit.Add(1)
+		qt.touch()
 		return qt
 	}
-	cacheMiss++
-	qt = &Quadtree{childs.NE.Level + 1, childs, childs.population(), nil}
-	if qt.Population == 0 || qt.Level <= 16 {
-		nodeMap[childs] = qt
+	cacheMiss.Add(1)
+	qt = newNode(childs)
+	if qt.cacheable() && !cacheFrozen.Load() {
+		qt = cachePut(childs, qt)
+	}
+	return qt
+}
+
+// newNode builds a node from childs without looking at the cache
+func newNode(childs Childs) *Quadtree {
+	space := childs.NE.space
+	if childs.SE.space != space || childs.SW.space != space || childs.NW.space != space {
+		panic("childs of a quadtree have to belong to the same rule")
+	}
+	return &Quadtree{Level: childs.NE.Level + 1, Childs: childs, Population: childs.population(), space: space, used: cacheEpoch.Load()}
+}
+
+// touch stamps qt with the current epoch. The stamp is only written when it changes, so hot nodes aren't written on every hit.
+func (qt *Quadtree) touch() {
+	epoch := cacheEpoch.Load()
+	if atomic.LoadUint32(&qt.used) != epoch {
+		atomic.StoreUint32(&qt.used, epoch)
+	}
+}
+
+// cacheable reports whether NewTree keeps qt in the cache. Big nodes with live cells are rarely built twice.
+func (qt *Quadtree) cacheable() bool {
+	return qt.IsEmpty() || qt.Level <= 16
+}
+
+// cachePut adds qt to the node cache unless another goroutine cached a node for childs in the meantime.
+// It returns the node that ended up in the cache.
+func cachePut(childs Childs, qt *Quadtree) *Quadtree {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	if cached, ok := nodeCache.Get(childs); ok {
+		return cached
 	}
+	nodeCache.Put(childs, qt)
 	return qt
 }
 
-// EmptyTree returns an complete tree were all leaf nodes are dead cells
+// LookupNode returns the cached quadtree for childs, if any. Unlike NewTree it neither inserts into the cache nor counts hits and misses.
+func LookupNode(childs Childs) (*Quadtree, bool) {
+	return cacheGet(childs)
+}
+
+// emptyTrees and fullTrees memoize the results of EmptyTree and FullTree by rule and level.
+// They have to be reset whenever nodes are removed from the cache.
+var emptyTrees, fullTrees map[*ruleSpace][]*Quadtree
+
+// EmptyTree returns an complete tree were all leaf nodes are dead cells, under Conway's rule
 func EmptyTree(level uint) *Quadtree {
+	return conwaySpace.emptyTree(level)
+}
+
+// FullTree returns a complete tree were all leaf nodes are live cells, under Conway's rule.
+// Its population is 4^level, from level 32 on Population saturates and only PopulationBig counts it.
+func FullTree(level uint) *Quadtree {
+	return conwaySpace.fullTree(level)
+}
+
+// emptyTree returns an empty tree of the given level under the rule of s
+func (s *ruleSpace) emptyTree(level uint) *Quadtree {
+	return s.uniformTree(level, s.dead, &emptyTrees)
+}
+
+// fullTree returns a tree of the given level with only live cells under the rule of s
+func (s *ruleSpace) fullTree(level uint) *Quadtree {
+	return s.uniformTree(level, s.live, &fullTrees)
+}
+
+// uniformTree returns the tree of the given level with all leaves set to leaf, memoized in *memo
+func (s *ruleSpace) uniformTree(level uint, leaf *Quadtree, memo *map[*ruleSpace][]*Quadtree) *Quadtree {
 	if level == 0 || level+1 == 0 || level+2 == 0 {
-		return deadLeaf
+		return leaf
+	}
+	if qt := s.memoizedTree(level, memo); qt != nil {
+		return qt
 	}
-	child := EmptyTree(level - 1)
-	return NewTree(Childs{child, child, child, child})
+	child := s.uniformTree(level-1, leaf, memo)
+	qt := NewTree(Childs{child, child, child, child})
+	if qt.isCached() { // not built while the cache is frozen
+		cacheMutex.Lock()
+		defer cacheMutex.Unlock()
+		if *memo == nil {
+			*memo = make(map[*ruleSpace][]*Quadtree)
+		}
+		trees := (*memo)[s]
+		for uint(len(trees)) <= level {
+			trees = append(trees, nil)
+		}
+		trees[level] = qt
+		(*memo)[s] = trees
+	}
+	return qt
+}
+
+// memoizedTree returns the tree of the given level under the rule of s from *memo, nil if there is none
+func (s *ruleSpace) memoizedTree(level uint, memo *map[*ruleSpace][]*Quadtree) *Quadtree {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	if trees := (*memo)[s]; level < uint(len(trees)) {
+		return trees[level]
+	}
+	return nil
 }
 
 // grow returns a Quadtree four times as big (adds one more layer)
 // old Quadtree sub trees are in the center of new Quadtree
 func (qt *Quadtree) grow() *Quadtree {
-	if qt.Level >= 63 { //this depends on dim, would be better not to hardcode
+	return qt.growWith(NewTree)
+}
+
+// growWith is grow building the new nodes with newTree
+func (qt *Quadtree) growWith(newTree func(Childs) *Quadtree) *Quadtree {
+	if qt.Level >= MaxLevel {
 		panic(fmt.Sprintf("Quadtree can't grow beyond level %v", qt.Level))
 	}
 	if qt.Level < 1 {
@@ -105,39 +364,72 @@ func (qt *Quadtree) grow() *Quadtree {
 	}
 
 	//fmt.Println(qt)
-	emptyChild := EmptyTree(qt.Level - 1)
-	return NewTree(Childs{
-		SE: NewTree(Childs{emptyChild, emptyChild, qt.SE, emptyChild}),
-		SW: NewTree(Childs{emptyChild, emptyChild, emptyChild, qt.SW}),
-		NW: NewTree(Childs{qt.NW, emptyChild, emptyChild, emptyChild}),
-		NE: NewTree(Childs{emptyChild, qt.NE, emptyChild, emptyChild})})
+	emptyChild := qt.space.emptyTree(qt.Level - 1)
+	return newTree(Childs{
+		SE: newTree(Childs{emptyChild, emptyChild, qt.SE, emptyChild}),
+		SW: newTree(Childs{emptyChild, emptyChild, emptyChild, qt.SW}),
+		NW: newTree(Childs{qt.NW, emptyChild, emptyChild, emptyChild}),
+		NE: newTree(Childs{emptyChild, qt.NE, emptyChild, emptyChild})})
 }
 
-// GrowToFit returns a Quadtree big enough to include (x,y)
+// GrowToFit returns a Quadtree big enough to include (x,y).
+// It panics with the error of GrowToFitErr if (x,y) lies beyond the coordinate range of a tree of MaxLevel.
 func (qt *Quadtree) GrowToFit(x, y Dim) *Quadtree {
-	for true {
-		maxCoordinate := Dim(1) << (qt.Level - 1)
-		// fmt.Printf("growing to %v, %v. Reached maxcoordinate %v\n", x, y, maxCoordinate)
-		if x <= maxCoordinate-1 && y <= maxCoordinate-1 && x >= -maxCoordinate && y >= -maxCoordinate {
-			break
-		}
+	grown, err := qt.GrowToFitErr(x, y)
+	if err != nil {
+		panic(err)
+	}
+	return grown
+}
+
+// GrowToFitErr returns a Quadtree big enough to include (x,y), or an error if not even a tree of MaxLevel does.
+// The check comes first, so the loop ends at MaxLevel at the latest.
+func (qt *Quadtree) GrowToFitErr(x, y Dim) (*Quadtree, error) {
+	if !levelContains(MaxLevel, x, y) {
+		return nil, fmt.Errorf("(%v, %v) lies beyond the coordinate range of the biggest tree of level %v", x, y, MaxLevel)
+	}
+	for !qt.contains(x, y) {
 		qt = qt.grow()
 	}
-	return qt
+	return qt, nil
+}
+
+// GrowToFitRect returns a Quadtree big enough to include the rectangle from (minX,minY) to (maxX,maxY), inclusive.
+// Growing keeps the center, so it's enough to fit the two opposite corners. Coordinates range from -2^(l-1)
+// to 2^(l-1)-1, so a rectangle reaching 2^(l-1) at its positive edge needs one more level than its negative edge.
+func (qt *Quadtree) GrowToFitRect(minX, minY, maxX, maxY Dim) *Quadtree {
+	return qt.GrowToFit(minX, minY).GrowToFit(maxX, maxY)
+}
+
+// contains reports whether (x,y) lies in the coordinate range of qt
+func (qt *Quadtree) contains(x, y Dim) bool {
+	return levelContains(qt.Level, x, y)
+}
+
+// levelContains reports whether (x,y) lies in the coordinate range of a tree of the given level
+func levelContains(level uint, x, y Dim) bool {
+	maxCoordinate := Dim(1) << (level - 1)
+	return x <= maxCoordinate-1 && y <= maxCoordinate-1 && x >= -maxCoordinate && y >= -maxCoordinate
 }
 
-// SetCell uses findLeaf() to find the corresponding leaf and sets it to value
+// SetCell uses findLeaf() to find the corresponding leaf and sets it to value.
+// Every value but 0 makes the cell alive with state 1, see SetCellState for other states.
+// It panics if (x,y) lies outside of qt, see SetCellGrow for a variant that grows the tree instead.
 func (qt *Quadtree) SetCell(x, y Dim, value Dim) *Quadtree {
-	if qt.Level == 0 {
+	if value == 0 {
+		return qt.SetCellState(x, y, 0)
+	}
+	return qt.SetCellState(x, y, 1)
+}
+
+// SetCellState sets the cell at (x,y) to state, like SetCell it panics if (x,y) lies outside of qt
+func (qt *Quadtree) SetCellState(x, y Dim, state State) *Quadtree {
+	if qt.IsLeaf() {
 		// assert that coordinates reached one of the four
 		if x < -1 || x > 0 || y < -1 || y > 0 {
 			panic(fmt.Sprintln("reached leaf node with coordinates to big, probably didn't grow univers to fit (x,y): (", x, y, ")"))
 		}
-		if value == 0 {
-			return deadLeaf
-		} else {
-			return liveLeaf
-		}
+		return qt.space.leaves[state]
 	}
 
 	distanceToOrigin := Dim(1) << (qt.Level - 2) // 0 in case of Level 2 and 1
@@ -145,30 +437,126 @@ func (qt *Quadtree) SetCell(x, y Dim, value Dim) *Quadtree {
 	// south/north east/west quadrant
 	if x >= 0 {
 		if y >= 0 {
-			return NewTree(Childs{qt.SE.SetCell(x-distanceToOrigin, y-distanceToOrigin, value), qt.SW, qt.NW, qt.NE})
+			return NewTree(Childs{qt.SE.SetCellState(x-distanceToOrigin, y-distanceToOrigin, state), qt.SW, qt.NW, qt.NE})
 		} else {
-			return NewTree(Childs{qt.SE, qt.SW, qt.NW, qt.NE.SetCell(x-distanceToOrigin, y+distanceToOrigin, value)})
+			return NewTree(Childs{qt.SE, qt.SW, qt.NW, qt.NE.SetCellState(x-distanceToOrigin, y+distanceToOrigin, state)})
 		}
 	} else {
 		if y >= 0 {
-			return NewTree(Childs{qt.SE, qt.SW.SetCell(x+distanceToOrigin, y-distanceToOrigin, value), qt.NW, qt.NE})
+			return NewTree(Childs{qt.SE, qt.SW.SetCellState(x+distanceToOrigin, y-distanceToOrigin, state), qt.NW, qt.NE})
 		} else {
-			return NewTree(Childs{qt.SE, qt.SW, qt.NW.SetCell(x+distanceToOrigin, y+distanceToOrigin, value), qt.NE})
+			return NewTree(Childs{qt.SE, qt.SW, qt.NW.SetCellState(x+distanceToOrigin, y+distanceToOrigin, state), qt.NE})
+		}
+	}
+}
+
+// SetCellGrow sets the cell at (x,y) to value like SetCell, but grows the tree to fit (x,y) first.
+// The result may have a higher level than qt.
+func (qt *Quadtree) SetCellGrow(x, y, value Dim) *Quadtree {
+	return qt.GrowToFit(x, y).SetCell(x, y, value)
+}
+
+// SetCellTracked is SetCell that also returns the region which changed, so a renderer only has to repaint it.
+// The region is the cell itself, or empty if it already had the given state.
+func (qt *Quadtree) SetCellTracked(x, y Dim, value Dim) (*Quadtree, Rect) {
+	if (qt.Cell(x, y) != 0) == (value != 0) {
+		return qt, Rect{0, 0, -1, -1}
+	}
+	return qt.SetCell(x, y, value), Rect{x, y, x, y}
+}
+
+// Track applies mutate to qt and also returns the smallest region in which the result differs from qt, so any
+// mutation like SetCells, FillRegion or pasting a pattern with Overlay can be tracked like SetCellTracked.
+// The region is empty if nothing changed. Only subtrees that differ between qt and the result are descended.
+func (qt *Quadtree) Track(mutate func(qt *Quadtree) *Quadtree) (*Quadtree, Rect) {
+	result := mutate(qt)
+	box, ok := changes(alignLevels(qt, result)).boundingBox()
+	if !ok {
+		return result, Rect{0, 0, -1, -1}
+	}
+	return result, box
+}
+
+// SetCells sets the cells at (X,Y) to V like a sequence of SetCell calls, later entries win over earlier ones.
+// The tree grows to fit all cells. The cells are grouped by quadrant recursively, so every changed node is built
+// once for the whole batch instead of rebuilding the path from the root for every cell.
+func (qt *Quadtree) SetCells(cells []struct{ X, Y, V Dim }) *Quadtree {
+	for _, c := range cells {
+		qt = qt.GrowToFit(c.X, c.Y)
+	}
+	// the recursion reorders both slices, so the caller's cells are left untouched
+	work := append([]struct{ X, Y, V Dim }(nil), cells...)
+	return qt.setCells(qt.origin(), qt.origin(), work, make([]struct{ X, Y, V Dim }, len(cells)))
+}
+
+// setCells applies cells to qt, whose north west corner is at (x,y). scratch has the length of cells and
+// is used to group them by quadrant, so the recursion needs no further allocations.
+func (qt *Quadtree) setCells(x, y Dim, cells, scratch []struct{ X, Y, V Dim }) *Quadtree {
+	if len(cells) == 0 {
+		return qt
+	}
+	if qt.IsLeaf() {
+		if cells[len(cells)-1].V == 0 {
+			return qt.space.dead
+		}
+		return qt.space.live
+	}
+	half := Dim(1) << (qt.Level - 1)
+	quadrant := func(c struct{ X, Y, V Dim }) int {
+		i := 0
+		if c.X >= x+half {
+			i++
+		}
+		if c.Y >= y+half {
+			i += 2
 		}
+		return i
+	}
+	// stable counting sort into scratch, order NW, NE, SW, SE
+	var ends [4]int
+	for _, c := range cells {
+		ends[quadrant(c)]++
+	}
+	for i := 1; i < 4; i++ {
+		ends[i] += ends[i-1]
+	}
+	starts := [4]int{0, ends[0], ends[1], ends[2]}
+	next := starts
+	for _, c := range cells {
+		i := quadrant(c)
+		scratch[next[i]] = c
+		next[i]++
+	}
+	group := func(i int) ([]struct{ X, Y, V Dim }, []struct{ X, Y, V Dim }) {
+		return scratch[starts[i]:ends[i]], cells[starts[i]:ends[i]]
 	}
+	nw, nwScratch := group(0)
+	ne, neScratch := group(1)
+	sw, swScratch := group(2)
+	se, seScratch := group(3)
+	return NewTree(Childs{
+		NW: qt.NW.setCells(x, y, nw, nwScratch),
+		NE: qt.NE.setCells(x+half, y, ne, neScratch),
+		SW: qt.SW.setCells(x, y+half, sw, swScratch),
+		SE: qt.SE.setCells(x+half, y+half, se, seScratch),
+	})
 }
 
-// Cell find the corresponding leaf and returns it's value
+// Cell find the corresponding leaf and returns it's state as Dim, 1 for a live cell under a Life-like rule
 func (qt *Quadtree) Cell(x, y Dim) Dim {
-	leaf := qt.findLeaf(x, y)
-	return leaf.Population
+	return Dim(qt.CellState(x, y))
+}
+
+// CellState returns the state of the cell at (x,y), 0 for a dead cell
+func (qt *Quadtree) CellState(x, y Dim) State {
+	return qt.findLeaf(x, y).state
 }
 
 // findLeaf searches tree for leaf node at x,y.
 // If generatePath is true the path for this node will be build in case it didn't exist yet.
 // If generatePath is false and cell at x,y was not set, returns nil
 func (qt *Quadtree) findLeaf(x, y Dim) *Quadtree {
-	if qt.Level == 0 {
+	if qt.IsLeaf() {
 		// assert that coordinates reached one of the four
 		if x < -1 || x > 0 || y < -1 || y > 0 {
 			panic(fmt.Sprintln("reached leaf node with coordinates to big, probably didn't grow univers to fit (x,y): (", x, y, ")"))
@@ -198,21 +586,47 @@ func (qt *Quadtree) findLeaf(x, y Dim) *Quadtree {
 // on the x and y values that denote the min x and min y of qt in the global coordinate system.
 // The root qt has its origin at - 2^(l-1)
 func (qt *Quadtree) FindLifeCells(x, y Dim, callback func(x, y Dim)) {
-	if qt.Population == 0 {
-		return
+	qt.EachLiveCell(x, y, func(x, y Dim) bool {
+		callback(x, y)
+		return true
+	})
+}
+
+// LiveCells calls callback with the global coordinates of every live cell of the root qt
+func (qt *Quadtree) LiveCells(callback func(x, y Dim)) {
+	origin := qt.origin()
+	qt.FindLifeCells(origin, origin, callback)
+}
+
+// EachLiveCell calls fn for the live cells of qt like FindLifeCells, until fn returns false.
+// The childs of every node are visited in the order NW, NE, SW, SE and empty ones are skipped.
+func (qt *Quadtree) EachLiveCell(x, y Dim, fn func(x, y Dim) bool) {
+	qt.eachLiveCell(x, y, fn)
+}
+
+// eachLiveCell returns false as soon as fn does
+func (qt *Quadtree) eachLiveCell(x, y Dim, fn func(x, y Dim) bool) bool {
+	if qt.IsEmpty() {
+		return true
 	}
-	if qt.Level == 0 {
-		if qt.Population > 0 {
-			callback(x, y)
-		}
-		return
+	if qt.IsLeaf() {
+		return fn(x, y)
 	}
 	distance := Dim(1) << (qt.Level - 1) // 1 in case of Level 1
-	//fmt.Println(distance)
-	qt.SE.FindLifeCells(x+distance, y+distance, callback)
-	qt.SW.FindLifeCells(x, y+distance, callback)
-	qt.NW.FindLifeCells(x, y, callback)
-	qt.NE.FindLifeCells(x+distance, y, callback)
+	return qt.NW.eachLiveCell(x, y, fn) &&
+		qt.NE.eachLiveCell(x+distance, y, fn) &&
+		qt.SW.eachLiveCell(x, y+distance, fn) &&
+		qt.SE.eachLiveCell(x+distance, y+distance, fn)
+}
+
+// IsEmpty reports whether qt contains no live cells
+func (qt *Quadtree) IsEmpty() bool {
+	return qt.Population == 0
+}
+
+// IsLeaf reports whether qt is a leaf node (level 0)
+func (qt *Quadtree) IsLeaf() bool {
+	return qt.Level == 0
 }
 
 func (qt *Quadtree) childs() []*Quadtree {
@@ -235,14 +649,14 @@ func (qt *Quadtree) centeredSubnode() *Quadtree {
 }
 
 /**
-*   Return a new node one level down from two given nodes
-*   that contains the east centered two sub sub nodes from
-*   the west node and the west centered two sub sub nodes
-*   from the east node.
-*
-*   w.ne.se | e.nw.sw
-    w.se.ne | e.sw.nw
-*/
+ *   Return a new node one level down from two given nodes
+ *   that contains the east centered two sub sub nodes from
+ *   the west node and the west centered two sub sub nodes
+ *   from the east node.
+ *
+ *   w.ne.se | e.nw.sw
+ *   w.se.ne | e.sw.nw
+ */
 func centeredHorizontal(w, e *Quadtree) *Quadtree {
 	var se, sw, nw, ne *Quadtree
 	se = e.SW.NW
@@ -280,35 +694,60 @@ func (qt *Quadtree) centeredSubSubnode() *Quadtree {
 	return NewTree(Childs{se, sw, nw, ne})
 }
 
+// Bits4x4 returns the 16 cells of a level 2 node in the bit layout oneGen consumes: row by row from (-2,-2)
+// to (1,1), with the first cell in the most significant bit. FromBits(2, ...) is the inverse.
+func (qt *Quadtree) Bits4x4() (uint16, error) {
+	if qt.Level != 2 {
+		return 0, fmt.Errorf("Bits4x4 needs a quadtree of level 2, got level %v", qt.Level)
+	}
+	return qt.bits4x4(), nil
+}
+
+// bits4x4 is Bits4x4 for a node known to be of level 2
+func (qt *Quadtree) bits4x4() uint16 {
+	// read the 16 leaves row by row, without any coordinate math
+	leaves := [16]*Quadtree{
+		qt.NW.NW, qt.NW.NE, qt.NE.NW, qt.NE.NE,
+		qt.NW.SW, qt.NW.SE, qt.NE.SW, qt.NE.SE,
+		qt.SW.NW, qt.SW.NE, qt.SE.NW, qt.SE.NE,
+		qt.SW.SW, qt.SW.SE, qt.SE.SW, qt.SE.SE,
+	}
+	allbits := uint16(0)
+	for _, leaf := range leaves {
+		allbits = (allbits << 1) | uint16(leaf.Population)
+	}
+	return allbits
+}
+
 /*
 *   At level 2, we can use slow simulation to compute the next
 *   generation.  We use bitmask tricks.
  */
 func (qt *Quadtree) slowSimulation() *Quadtree {
+	return qt.slowSimulationWith(NewTree)
+}
+
+// slowSimulationWith is slowSimulation building the result with newTree
+func (qt *Quadtree) slowSimulationWith(newTree func(Childs) *Quadtree) *Quadtree {
 	if qt.Level != 2 {
 		panic(fmt.Sprint("slowSimulation only possible for quadtree of size 2"))
 	}
-	allbits := uint16(0)
-	for y := Dim(-2); y < 2; y++ {
-		for x := Dim(-2); x < 2; x++ {
-			allbits = (allbits << 1) + uint16(qt.Cell(x, y))
-		}
-	}
-
-	return NewTree(Childs{oneGen(allbits), oneGen(allbits >> 1), oneGen(allbits >> 5), oneGen(allbits >> 4)})
+	allbits := qt.bits4x4()
+	s := qt.space
+	return newTree(Childs{s.oneGen(allbits), s.oneGen(allbits >> 1), s.oneGen(allbits >> 5), s.oneGen(allbits >> 4)})
 }
 
 /**
  *   Given an integer with a bitmask indicating which bits are
  *   set in the neighborhood, calculate whether this cell is
- *   alive or dead in the next generation.  The bottom three
+ *   alive or dead in the next generation under the rule of s.  The bottom three
  *   bits are the south neighbors; bits 4..6 are the current
  *   row with bit 5 being the cell itself, and bits 9..11
  *   are the north neighbors.
  */
-func oneGen(bitmask uint16) *Quadtree {
+func (s *ruleSpace) oneGen(bitmask uint16) *Quadtree {
 	if bitmask == 0 {
-		return deadLeaf
+		return s.dead
 	}
 	self := (bitmask >> 5) & 1
 	bitmask &= 0x757 // mask out bits we don't care about 0b0111 0101 0111
@@ -320,69 +759,464 @@ func oneGen(bitmask uint16) *Quadtree {
 		neighborCount++
 		bitmask &= bitmask - 1 // clear least significant bit
 	}
-	if neighborCount == 3 || (neighborCount == 2 && self != 0) {
-		return liveLeaf
+	if s.rule.nextState(self != 0, neighborCount) {
+		return s.live
 	} else {
-		return deadLeaf
+		return s.dead
 	}
 }
 
-/*NextGeneration returns cached result from qt.next or recursivly computes the next generation.
-    It works
-    by constructing nine subnodes that are each a quarter the size
-    of the current node in each dimension, and combining these in
-    groups of four, building subnodes from these, and then
-    recursively invoking the NextGeneration function and combining
-    those final results into a single return value that is one
-    half the size of the current node and advanced one generation in
-    time.
-    qt.next will contain the result after the call
+/*
+NextGeneration returns cached result from qt.next or recursivly computes the next generation.
+It works
+by constructing nine subnodes that are each a quarter the size
+of the current node in each dimension, and combining these in
+groups of four, building subnodes from these, and then
+recursively invoking the NextGeneration function and combining
+those final results into a single return value that is one
+half the size of the current node and advanced one generation in
+time.
+qt.next will contain the result after the call
 
-	Check NextGen(), that keeps the tree level constant.
+Check NextGen(), that keeps the tree level constant.
 */
 func (qt *Quadtree) NextGeneration() *Quadtree {
-	if qt.next != nil {
-		return qt.next
+	if next := qt.next.Load(); next != nil {
+		return next
 	}
 
 	if qt.Level == 2 {
 		return qt.slowSimulation()
 	}
 
-	n00 := qt.NW.centeredSubnode()
-	n01 := centeredHorizontal(qt.NW, qt.NE)
-	n02 := qt.NE.centeredSubnode()
-	n10 := centeredVertical(qt.NW, qt.SW)
-	n11 := qt.centeredSubSubnode()
-	n12 := centeredVertical(qt.NE, qt.SE)
-	n20 := qt.SW.centeredSubnode()
-	n21 := centeredHorizontal(qt.SW, qt.SE)
-	n22 := qt.SE.centeredSubnode()
-
+	q := qt.quadrantNodes()
 	nextGen := NewTree(Childs{
-		NW: NewTree(Childs{NW: n00, NE: n01, SW: n10, SE: n11}).NextGeneration(),
-		NE: NewTree(Childs{NW: n01, NE: n02, SW: n11, SE: n12}).NextGeneration(),
-		SW: NewTree(Childs{NW: n10, NE: n11, SW: n20, SE: n21}).NextGeneration(),
-		SE: NewTree(Childs{NW: n11, NE: n12, SW: n21, SE: n22}).NextGeneration(),
+		NW: q[NorthWest].NextGeneration(),
+		NE: q[NorthEast].NextGeneration(),
+		SW: q[SouthWest].NextGeneration(),
+		SE: q[SouthEast].NextGeneration(),
 	})
 
-	qt.next = nextGen
+	qt.setNext(nextGen)
 
 	return nextGen
 }
 
+// quadrantNodes returns for each quadrant the node one level smaller than qt whose next generation is
+// that quadrant of the next generation of qt. They are assembled from the nine overlapping subnodes of qt.
+func (qt *Quadtree) quadrantNodes() [4]*Quadtree {
+	return qt.quadrantNodesWith(NewTree)
+}
+
+// quadrantNodesWith is quadrantNodes building the nodes with newTree
+func (qt *Quadtree) quadrantNodesWith(newTree func(Childs) *Quadtree) [4]*Quadtree {
+	// the grandchilds in a 4x4 grid, n[i][j] is centered on the corner shared by g[i][j] and g[i+1][j+1]
+	g := [4][4]*Quadtree{
+		{qt.NW.NW, qt.NW.NE, qt.NE.NW, qt.NE.NE},
+		{qt.NW.SW, qt.NW.SE, qt.NE.SW, qt.NE.SE},
+		{qt.SW.NW, qt.SW.NE, qt.SE.NW, qt.SE.NE},
+		{qt.SW.SW, qt.SW.SE, qt.SE.SW, qt.SE.SE},
+	}
+	var n [3][3]*Quadtree
+	for i := range n {
+		for j := range n[i] {
+			n[i][j] = newTree(Childs{NW: g[i][j].SE, NE: g[i][j+1].SW, SW: g[i+1][j].NE, SE: g[i+1][j+1].NW})
+		}
+	}
+
+	return [4]*Quadtree{
+		NorthWest: newTree(Childs{NW: n[0][0], NE: n[0][1], SW: n[1][0], SE: n[1][1]}),
+		NorthEast: newTree(Childs{NW: n[0][1], NE: n[0][2], SW: n[1][1], SE: n[1][2]}),
+		SouthWest: newTree(Childs{NW: n[1][0], NE: n[1][1], SW: n[2][0], SE: n[2][1]}),
+		SouthEast: newTree(Childs{NW: n[1][1], NE: n[1][2], SW: n[2][1], SE: n[2][2]}),
+	}
+}
+
+// contextCheckLevel is the smallest level at which nextGenerationContext checks for cancellation.
+// Smaller nodes are computed by NextGeneration without any checks.
+const contextCheckLevel = 10
+
+// nextGenerationContext is NextGeneration that gives up with the error of ctx once it's done.
+// Next generations computed until then stay cached.
+func (qt *Quadtree) nextGenerationContext(ctx context.Context) (*Quadtree, error) {
+	if qt.Level < contextCheckLevel || qt.next.Load() != nil {
+		return qt.NextGeneration(), nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var next [4]*Quadtree
+	for i, node := range qt.quadrantNodes() {
+		n, err := node.nextGenerationContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		next[i] = n
+	}
+	nextGen := NewTree(Childs{NW: next[NorthWest], NE: next[NorthEast], SW: next[SouthWest], SE: next[SouthEast]})
+	qt.setNext(nextGen)
+	return nextGen, nil
+}
+
+// Quadrant identifies one of the four childs of a quadtree
+type Quadrant int
+
+// The four quadrants of a quadtree
+const (
+	NorthWest Quadrant = iota
+	NorthEast
+	SouthWest
+	SouthEast
+)
+
+// child returns the sub-quadtree in quadrant q
+func (qt *Quadtree) child(q Quadrant) *Quadtree {
+	switch q {
+	case NorthWest:
+		return qt.NW
+	case NorthEast:
+		return qt.NE
+	case SouthWest:
+		return qt.SW
+	case SouthEast:
+		return qt.SE
+	}
+	panic(fmt.Sprintf("unknown quadrant %v", q))
+}
+
+// NextGenerationQuadrant returns the quadrant q of NextGeneration() without computing the other three quadrants.
+// Only the four of the nine subnodes that overlap q are built.
+func (qt *Quadtree) NextGenerationQuadrant(q Quadrant) *Quadtree {
+	if qt.Level < 2 {
+		panic(fmt.Sprintf("next generation needs a quadtree of level 2 or more, got level %v", qt.Level))
+	}
+	if qt.next.Load() != nil || qt.Level == 2 {
+		return qt.NextGeneration().child(q)
+	}
+
+	n11 := qt.centeredSubSubnode()
+	switch q {
+	case NorthWest:
+		return NewTree(Childs{
+			NW: qt.NW.centeredSubnode(), NE: centeredHorizontal(qt.NW, qt.NE),
+			SW: centeredVertical(qt.NW, qt.SW), SE: n11}).NextGeneration()
+	case NorthEast:
+		return NewTree(Childs{
+			NW: centeredHorizontal(qt.NW, qt.NE), NE: qt.NE.centeredSubnode(),
+			SW: n11, SE: centeredVertical(qt.NE, qt.SE)}).NextGeneration()
+	case SouthWest:
+		return NewTree(Childs{
+			NW: centeredVertical(qt.NW, qt.SW), NE: n11,
+			SW: qt.SW.centeredSubnode(), SE: centeredHorizontal(qt.SW, qt.SE)}).NextGeneration()
+	case SouthEast:
+		return NewTree(Childs{
+			NW: n11, NE: centeredVertical(qt.NE, qt.SE),
+			SW: centeredHorizontal(qt.SW, qt.SE), SE: qt.SE.centeredSubnode()}).NextGeneration()
+	}
+	panic(fmt.Sprintf("unknown quadrant %v", q))
+}
+
 var mutex = &sync.Mutex{}
 
+// stepKey identifies the result of NextGenerationStep
+type stepKey struct {
+	qt   *Quadtree
+	step uint
+}
+
+// stepCache holds the results of NextGenerationStep for steps > 0, it's emptied together with the node cache
+var stepCache = make(map[stepKey]*Quadtree)
+
+// NextGenerationStep returns the center of qt, one level smaller, advanced by 2^step generations.
+// step can be at most qt.Level-2, with step 0 it's the same as NextGeneration. Results are cached per step,
+// so repeated jumps over the same pattern only compute new nodes.
+func (qt *Quadtree) NextGenerationStep(step uint) *Quadtree {
+	if qt.Level < 2 || step > qt.Level-2 {
+		panic(fmt.Sprintf("a quadtree of level %v can't advance 2^%v generations", qt.Level, step))
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	return qt.nextGenerationStep(step)
+}
+
+func (qt *Quadtree) nextGenerationStep(step uint) *Quadtree {
+	if step == 0 {
+		return qt.NextGeneration()
+	}
+	key := stepKey{qt, step}
+	if next, ok := stepCache[key]; ok {
+		return next
+	}
+
+	// at full speed both rounds advance by half of the step, otherwise only the
+	// second round advances and the first one just takes the centers
+	full := step == qt.Level-2
+	inner := step
+	if full {
+		inner = step - 1
+	}
+	var r [3][3]*Quadtree
+	for i, row := range qt.overlappingSubnodes() {
+		for j, node := range row {
+			if full {
+				r[i][j] = node.nextGenerationStep(inner)
+			} else {
+				r[i][j] = node.centeredSubnode()
+			}
+		}
+	}
+	combine := func(i, j int) *Quadtree {
+		return NewTree(Childs{NW: r[i][j], NE: r[i][j+1], SW: r[i+1][j], SE: r[i+1][j+1]}).nextGenerationStep(inner)
+	}
+	next := NewTree(Childs{NW: combine(0, 0), NE: combine(0, 1), SW: combine(1, 0), SE: combine(1, 1)})
+
+	if !cacheFrozen.Load() {
+		stepCache[key] = next
+	}
+	return next
+}
+
+// overlappingSubnodes returns the nine nodes one level smaller than qt in a 3x3 grid, each overlapping its neighbors by half
+func (qt *Quadtree) overlappingSubnodes() [3][3]*Quadtree {
+	return [3][3]*Quadtree{
+		{
+			qt.NW,
+			NewTree(Childs{NW: qt.NW.NE, NE: qt.NE.NW, SW: qt.NW.SE, SE: qt.NE.SW}),
+			qt.NE,
+		},
+		{
+			NewTree(Childs{NW: qt.NW.SW, NE: qt.NW.SE, SW: qt.SW.NW, SE: qt.SW.NE}),
+			NewTree(Childs{NW: qt.NW.SE, NE: qt.NE.SW, SW: qt.SW.NE, SE: qt.SE.NW}),
+			NewTree(Childs{NW: qt.NE.SW, NE: qt.NE.SE, SW: qt.SE.NW, SE: qt.SE.NE}),
+		},
+		{
+			qt.SW,
+			NewTree(Childs{NW: qt.SW.NE, NE: qt.SE.NW, SW: qt.SW.SE, SE: qt.SE.SW}),
+			qt.SE,
+		},
+	}
+}
+
 // NextGen should be used to calulate next generation, grows the tree and changes the Quadree to new one with new state
 func (qt *Quadtree) NextGen() *Quadtree {
+	nextGen, _ := qt.NextGenContext(context.Background())
+	return nextGen
+}
+
+// NextGenContext is NextGen that stops early and returns the error of ctx once ctx is done.
+// The context is checked at every node of level contextCheckLevel or above, so huge patterns can be interrupted
+// while small ones pay nothing for it. The work done before the cancellation stays cached for the next attempt.
+func (qt *Quadtree) NextGenContext(ctx context.Context) (*Quadtree, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if size := cacheSize(); size > cacheLimit {
+		evictLeastRecentlyUsed(size-cacheLimit*3/4, qt)
+	}
+	cacheEpoch.Add(1)
+	grown := qt.grow()
+	if center := qt.next.Load(); grown.next.Load() == nil && center != nil && qt.Level >= 3 {
+		next, err := grown.nextGenerationAround(ctx, center)
+		if err != nil {
+			return nil, err
+		}
+		grown.setNext(next)
+	}
+	nextGen, err := grown.nextGenerationContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if qt.next.Load() == nil && qt.Level >= 2 {
+		// the center of the next generation of the grown tree is the next generation of qt
+		qt.setNext(nextGen.centeredSubnode())
+	}
+	publishCounters()
+	return nextGen, nil
+}
+
+// setNext memoizes next as the next generation of qt
+func (qt *Quadtree) setNext(next *Quadtree) {
+	if cacheFrozen.Load() && qt.isCached() && !next.isCached() {
+		return // a cached node must not refer to a node that was built while the cache was frozen
+	}
+	qt.next.Store(next)
+}
+
+// nextGenerationAround computes NextGeneration() of a tree grown from a quadtree whose next generation center is known.
+// The result is assembled from 16 pieces of which the 4 in the center are taken from center,
+// only the 12 pieces of the outer ring are computed.
+func (qt *Quadtree) nextGenerationAround(ctx context.Context, center *Quadtree) (*Quadtree, error) {
+	piece := Dim(1) << (qt.Level - 3)
+	var pieces [4][4]*Quadtree
+	for j := Dim(0); j < 4; j++ {
+		for i := Dim(0); i < 4; i++ {
+			if (i == 1 || i == 2) && (j == 1 || j == 2) {
+				continue
+			}
+			// the node two levels down centered on the piece
+			next, err := qt.subnode(qt.Level-2, 2*piece+i*piece-piece/2, 2*piece+j*piece-piece/2).nextGenerationContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			pieces[j][i] = next
+		}
+	}
+	pieces[1][1], pieces[1][2], pieces[2][1], pieces[2][2] = center.NW, center.NE, center.SW, center.SE
+	return NewTree(Childs{
+		NW: NewTree(Childs{NW: pieces[0][0], NE: pieces[0][1], SW: pieces[1][0], SE: pieces[1][1]}),
+		NE: NewTree(Childs{NW: pieces[0][2], NE: pieces[0][3], SW: pieces[1][2], SE: pieces[1][3]}),
+		SW: NewTree(Childs{NW: pieces[2][0], NE: pieces[2][1], SW: pieces[3][0], SE: pieces[3][1]}),
+		SE: NewTree(Childs{NW: pieces[2][2], NE: pieces[2][3], SW: pieces[3][2], SE: pieces[3][3]}),
+	}), nil
+}
+
+// subnode returns the node of the given level with its north west corner at (x,y), relative to the north west corner of qt
+func (qt *Quadtree) subnode(level uint, x, y Dim) *Quadtree {
+	if level == qt.Level {
+		return qt
+	}
+	if qt.IsEmpty() {
+		return qt.space.emptyTree(level)
+	}
+	half := Dim(1) << (qt.Level - 1)
+	size := Dim(1) << level
+	if x+size <= half || x >= half {
+		if y+size <= half || y >= half {
+			// within one child
+			child := qt.NW
+			switch {
+			case x >= half && y >= half:
+				child = qt.SE
+			case x >= half:
+				child = qt.NE
+			case y >= half:
+				child = qt.SW
+			}
+			return child.subnode(level, x%half, y%half)
+		}
+	}
+	quarter := size / 2
+	return NewTree(Childs{
+		NW: qt.subnode(level-1, x, y),
+		NE: qt.subnode(level-1, x+quarter, y),
+		SW: qt.subnode(level-1, x, y+quarter),
+		SE: qt.subnode(level-1, x+quarter, y+quarter),
+	})
+}
+
+// CompactCache removes all nodes from the cache which aren't reachable from roots or pinned trees, including their cached next generations.
+// Trees which are still in use have to be passed as roots or pinned, otherwise structurally identical trees built later won't share their nodes.
+func CompactCache(roots ...*Quadtree) {
 	mutex.Lock()
 	defer mutex.Unlock()
-	if len(nodeMap) > 13000000 {
-		log.Println("Cache contains", len(nodeMap), "entries. Empty cache to free memory.")
-		nodeMap = make(NodeMap) //free memory from old map
-		runtime.GC()
+	cacheMutex.RLock()
+	old := nodeMap
+	cacheMutex.RUnlock()
+	if old == nil { // custom cache
+		return
 	}
-	return qt.grow().NextGeneration()
+	reachable := make(map[*Quadtree]struct{})
+	for _, root := range roots {
+		root.markReachable(reachable)
+	}
+	for root := range pinned {
+		root.markReachable(reachable)
+	}
+	compacted := make(NodeMap, len(reachable))
+	cacheMutex.RLock()
+	for childs, qt := range old {
+		if _, ok := reachable[qt]; ok {
+			compacted[childs] = qt
+		}
+	}
+	cacheMutex.RUnlock()
+	resetCache(compacted)
+}
+
+// markReachable adds qt, its sub-quadtrees and their next generations to reachable
+func (qt *Quadtree) markReachable(reachable map[*Quadtree]struct{}) {
+	if _, ok := reachable[qt]; ok {
+		return
+	}
+	reachable[qt] = struct{}{}
+	if next := qt.next.Load(); next != nil {
+		next.markReachable(reachable)
+	}
+	if qt.IsLeaf() {
+		return
+	}
+	for _, child := range qt.childs() {
+		child.markReachable(reachable)
+	}
+}
+
+// evictLeastRecentlyUsed removes at least n nodes from the default cache, those of the epochs longest ago first.
+// The nodes of root and of pinned trees are never removed, neither are nodes used in the current epoch.
+// A node counts as used whenever one of the nodes above it is, so no cached node loses its cached childs.
+// The mutex has to be held.
+func evictLeastRecentlyUsed(n int, root *Quadtree) {
+	epoch := cacheEpoch.Load()
+	keep := make(map[*Quadtree]struct{})
+	root.collectNodes(keep)
+	pinnedNodes(keep)
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	if nodeMap == nil { // custom cache
+		return
+	}
+	for qt := range keep {
+		atomic.StoreUint32(&qt.used, epoch)
+	}
+
+	// pass the stamps down level by level, starting with the biggest nodes
+	var levels [][]*Quadtree
+	for _, qt := range nodeMap {
+		for uint(len(levels)) <= qt.Level {
+			levels = append(levels, nil)
+		}
+		levels[qt.Level] = append(levels[qt.Level], qt)
+	}
+	perEpoch := make(map[uint32]int)
+	for level := len(levels) - 1; level >= 0; level-- {
+		for _, qt := range levels[level] {
+			used := atomic.LoadUint32(&qt.used)
+			perEpoch[used]++
+			for _, child := range qt.childs() {
+				if atomic.LoadUint32(&child.used) < used {
+					atomic.StoreUint32(&child.used, used)
+				}
+			}
+		}
+	}
+
+	// whole epochs are removed, so a removed node never has a cached parent that stays
+	epochs := make([]uint32, 0, len(perEpoch))
+	for e := range perEpoch {
+		if e < epoch {
+			epochs = append(epochs, e)
+		}
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	removed := 0
+	var cutoff uint32
+	for _, e := range epochs {
+		if removed >= n {
+			break
+		}
+		cutoff = e + 1
+		removed += perEpoch[e]
+	}
+	if removed == 0 {
+		return
+	}
+	for childs, qt := range nodeMap {
+		if atomic.LoadUint32(&qt.used) < cutoff {
+			delete(nodeMap, childs)
+		}
+	}
+	cacheEvicted.Add(uint64(removed))
+	// both may refer to removed nodes
+	emptyTrees, fullTrees = nil, nil
+	stepCache = make(map[stepKey]*Quadtree)
 }
 
 type buckets map[int]uint
@@ -398,44 +1232,197 @@ func (b *buckets) sortedKeys() []int {
 	return keys
 }
 
-// Stats about the quadtree and its cache
-func (qt *Quadtree) Stats() string {
+// cacheCounters is a snapshot of the cache statistics
+type cacheCounters struct {
+	size               int
+	hit, miss, evicted uint64
+}
+
+// lastCounters holds the counters as of the end of the last step, see StatsNonBlocking
+var lastCounters atomic.Pointer[cacheCounters]
+
+// publishCounters stores the current counters in lastCounters. The mutex has to be held.
+func publishCounters() {
+	lastCounters.Store(&cacheCounters{cacheSize(), cacheHit.Load(), cacheMiss.Load(), cacheEvicted.Load()})
+}
+
+// cacheSize returns the number of nodes in the default cache, 0 for a custom cache
+func cacheSize() int {
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	return len(nodeMap)
+}
+
+// CacheStats is a snapshot of the node cache for structured metrics
+type CacheStats struct {
+	Size     int          // number of cached nodes, 0 for a custom cache
+	Hits     uint64       // NewTree calls served from the cache
+	Misses   uint64       // NewTree calls that built a new node
+	Evicted  uint64       // nodes evicted by NextGen
+	HitRatio float64      // Hits / (Hits + Misses), 0 before the first lookup
+	Levels   map[uint]int // number of cached nodes per level
+}
+
+// CacheStats returns the statistics of the node cache that qt is built from. It waits for a step in progress.
+func (qt *Quadtree) CacheStats() CacheStats {
 	mutex.Lock()
 	defer mutex.Unlock()
+	publishCounters()
+	stats := CacheStats{
+		Size:    cacheSize(),
+		Hits:    cacheHit.Load(),
+		Misses:  cacheMiss.Load(),
+		Evicted: cacheEvicted.Load(),
+		Levels:  make(map[uint]int),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+
+	cacheMutex.RLock()
+	for _, v := range nodeMap {
+		stats.Levels[v.Level]++
+	}
+	cacheMutex.RUnlock()
+	return stats
+}
+
+// Stats about the quadtree and its cache, formatted for the console. See CacheStats for the numbers.
+func (qt *Quadtree) Stats() string {
+	stats := qt.CacheStats()
 	s := fmt.Sprintln("Level:", qt.Level)
 	s += fmt.Sprintln("Population:", qt.Population)
-	s += fmt.Sprintln("Cache Size:", len(nodeMap))
-	s += fmt.Sprintln("Cache Hit:", cacheHit)
-	s += fmt.Sprintln("Cache Miss:", cacheMiss)
+	s += fmt.Sprintln("Cache Size:", stats.Size)
+	s += fmt.Sprintln("Cache Hit:", stats.Hits)
+	s += fmt.Sprintln("Cache Miss:", stats.Misses)
+	s += fmt.Sprintln("Cache Evicted:", stats.Evicted)
 
 	buckets := make(buckets)
+	for level, count := range stats.Levels {
+		buckets[int(level)] = uint(count)
+	}
 
-	for _, v := range nodeMap {
-		buckets[int(v.Level)]++
+	for _, level := range buckets.sortedKeys() {
+		s += fmt.Sprintln(level, buckets[level])
 	}
+	return s
+}
 
-	for k := range buckets.sortedKeys() {
-		s += fmt.Sprintln(k, buckets[k])
+// StatsNonBlocking is like Stats but doesn't wait for a step in progress. It reports the cache counters
+// as of the end of the last completed step and leaves out the per level breakdown.
+func (qt *Quadtree) StatsNonBlocking() string {
+	counters := lastCounters.Load()
+	if counters == nil {
+		counters = &cacheCounters{}
 	}
+	s := fmt.Sprintln("Level:", qt.Level)
+	s += fmt.Sprintln("Population:", qt.Population)
+	s += fmt.Sprintln("Cache Size:", counters.size)
+	s += fmt.Sprintln("Cache Hit:", counters.hit)
+	s += fmt.Sprintln("Cache Miss:", counters.miss)
+	s += fmt.Sprintln("Cache Evicted:", counters.evicted)
 	return s
 }
 
+// DefaultCacheLimit is the number of cached nodes above which NextGen evicts nodes, unless changed with SetCacheLimit
+const DefaultCacheLimit = 13000000
+
+var cacheLimit = DefaultCacheLimit
+
+// SetCacheLimit sets the number of cached nodes above which NextGen evicts the least recently used nodes to free memory.
+// The cache then shrinks to three quarters of the limit. The nodes of the tree being stepped are kept,
+// evicted nodes of other trees keep working, but don't share nodes with trees built afterwards.
+func SetCacheLimit(n int) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	cacheLimit = n
+}
+
+// CacheLen returns the number of nodes in the default cache, 0 for a custom cache set with SetNodeCache.
+// It doesn't wait for a step in progress.
+func CacheLen() int {
+	return cacheSize()
+}
+
+// FreezeCache stops (frozen = true) or resumes adding new nodes to the cache.
+// While frozen, cached nodes are still used but missing ones are built without being stored,
+// so memory stays stable at the cost of recomputing nodes that aren't cached.
+func FreezeCache(frozen bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	cacheFrozen.Store(frozen)
+}
+
+// CacheEntriesByPopulation returns the number of cached nodes for each population
+func CacheEntriesByPopulation() map[Dim]int {
+	mutex.Lock()
+	defer mutex.Unlock()
+	entries := make(map[Dim]int)
+	cacheMutex.RLock()
+	defer cacheMutex.RUnlock()
+	for _, v := range nodeMap {
+		entries[v.Population]++
+	}
+	return entries
+}
+
 func (qt *Quadtree) String() string {
-	if qt.Level == 0 {
+	if qt.IsLeaf() {
 		return fmt.Sprintf("Leaf %v", qt.Population)
 	}
 	spaces := strings.Repeat("  ", int(10-qt.Level))
 	return fmt.Sprintf("(L: %v)\n%vSE: %v\n%vSW: %v\n%vNW: %v\n%vNE: %v", qt.Level, spaces, qt.SE, spaces, qt.SW, spaces, qt.NW, spaces, qt.NE)
 }
 
+// PrintOptions controls the output of PrintWith
+type PrintOptions struct {
+	Output      io.Writer // defaults to os.Stdout
+	Coordinates bool      // prefix each row with its y coordinate
+	Live, Dead  string    // glyphs for live and dead cells
+	Separator   string    // printed between two cells of a row
+	Viewport    *Viewport // window to print, the whole tree if nil
+	WriteOptions
+}
+
 // Print to console a tree representation, only for small trees suitable
 func (qt *Quadtree) Print() {
-	maxCoord := Dim(1) << (qt.Level - 1)
-	for y := -maxCoord; y < maxCoord; y++ {
-		fmt.Printf("%3d: ", y)
-		for x := -maxCoord; x < maxCoord; x++ {
-			fmt.Print(qt.Cell(x, y), " ")
+	qt.PrintWith(PrintOptions{Coordinates: true, Live: "1", Dead: "0", Separator: " "})
+}
+
+// PrintWith prints a tree representation as configured by opts, only for small trees or viewports suitable.
+// Without coordinates and with "O" and "." as glyphs and no separator the output is plaintext (.cells) compatible.
+func (qt *Quadtree) PrintWith(opts PrintOptions) {
+	w := opts.Output
+	if w == nil {
+		w = os.Stdout
+	}
+	w = opts.writer(w)
+	window := qt.bounds()
+	if opts.Viewport != nil {
+		window = opts.Viewport.Rect()
+	}
+	if window.Empty() {
+		return
+	}
+	live := qt.liveGrid(window)
+	gutter := len(fmt.Sprint(window.MinY))
+	if l := len(fmt.Sprint(window.MaxY)); l > gutter {
+		gutter = l
+	}
+	for row := range live {
+		if opts.Coordinates {
+			fmt.Fprintf(w, "%*d: ", gutter, window.MinY+Dim(row))
+		}
+		for column, alive := range live[row] {
+			if column > 0 {
+				fmt.Fprint(w, opts.Separator)
+			}
+			if alive {
+				fmt.Fprint(w, opts.Live)
+			} else {
+				fmt.Fprint(w, opts.Dead)
+			}
 		}
-		fmt.Print("\n")
+		fmt.Fprint(w, "\n")
 	}
 }
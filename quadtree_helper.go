@@ -1,7 +1,6 @@
 package quadtree
 
 import (
-	"math/big"
 	"math/rand"
 	"testing"
 	"time"
@@ -9,21 +8,29 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// treeWithRandomPattern returns a tree with specified level and intialized with live cells where the corresponding bit in randomNumber is set.
-func treeWithRandomPattern(level uint) (qt *Quadtree, randomNumber *big.Int) {
+// randomPattern returns cells bits were each bit is set with probability density, sampled independently per cell from r
+func randomPattern(cells Dim, density float64, r *rand.Rand) []bool {
+	pattern := make([]bool, cells)
+	for i := range pattern {
+		pattern[i] = r.Float64() < density
+	}
+	return pattern
+}
+
+// clockRand returns a random source seeded with the current time
+func clockRand() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// treeWithRandomPattern returns a tree with specified level and intialized with live cells where the corresponding bit in pattern is set.
+// Every cell is alive with probability 1/2.
+func treeWithRandomPattern(level uint) (qt *Quadtree, pattern []bool) {
 	qt = EmptyTree(1)
 	for i := uint(1); i < level; i++ {
 		qt = qt.grow()
 	}
-	edgeLength := Dim(1) << level // level = 3 => 8, upperBound = 64
-	cellsInTree := uint(edgeLength * edgeLength)
-
-	// random big Int were each bit corresponds to one cell
-	upperBound := new(big.Int)
-	upperBound.SetInt64(1)
-	upperBound.Lsh(upperBound, cellsInTree-1)
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	randomNumber = new(big.Int).Rand(r, upperBound)
+	edgeLength := Dim(1) << level // level = 3 => 8, cells = 64
+	pattern = randomPattern(edgeLength*edgeLength, 0.5, clockRand())
 
 	for x := Dim(0); x < edgeLength; x++ {
 		for y := Dim(0); y < edgeLength; y++ {
@@ -31,33 +38,33 @@ func treeWithRandomPattern(level uint) (qt *Quadtree, randomNumber *big.Int) {
 			ux := x - edgeLength/2
 			uy := y - edgeLength/2
 
-			if randomNumber.Bit(int(bitPosition)) != 0 {
+			if pattern[bitPosition] {
 				qt = qt.SetCell(ux, uy, 1)
 			}
 		}
 	}
 
-	return qt, randomNumber
+	return qt, pattern
 }
 
+// FillTreeWithRandomPattern sets every cell of the square from (start,start) to (end-1,end-1) alive with probability 1/2.
+// The cells are sampled from a source seeded with the current time.
 func (qt *Quadtree) FillTreeWithRandomPattern(start Dim, end Dim) *Quadtree {
 	edgeLength := end - start
-	cellsInTree := uint(edgeLength * edgeLength)
-
-	// random big Int were each bit corresponds to one cell
-	upperBound := new(big.Int)
-	upperBound.SetInt64(1)
-	upperBound.Lsh(upperBound, cellsInTree-1)
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	randomNumber := new(big.Int).Rand(r, upperBound)
+	return qt.fillTreeWithPattern(start, end, randomPattern(edgeLength*edgeLength, 0.5, clockRand()))
+}
 
+// fillTreeWithPattern sets the cells of the square from (start,start) to (end-1,end-1) alive where the corresponding
+// bit in pattern is set and dead elsewhere
+func (qt *Quadtree) fillTreeWithPattern(start Dim, end Dim, pattern []bool) *Quadtree {
+	edgeLength := end - start
 	for x := Dim(0); x < edgeLength; x++ {
 		for y := Dim(0); y < edgeLength; y++ {
 			bitPosition := x*edgeLength + y
 			ux := x + start
 			uy := y + start
 
-			if randomNumber.Bit(int(bitPosition)) != 0 {
+			if pattern[bitPosition] {
 				qt = qt.SetCell(ux, uy, 1)
 			} else {
 				qt = qt.SetCell(ux, uy, 0)
@@ -67,16 +74,15 @@ func (qt *Quadtree) FillTreeWithRandomPattern(start Dim, end Dim) *Quadtree {
 	return qt
 }
 
-//assertRandomPattern asserts that the tree has live cells were the corresponding bit position in randomNumber is set
-func (qt *Quadtree) assertRandomPattern(t *testing.T, randomNumber *big.Int) {
+// assertRandomPattern asserts that the tree has live cells were the corresponding bit position in pattern is set
+func (qt *Quadtree) assertRandomPattern(t *testing.T, pattern []bool) {
 	edgeLength := Dim(1) << qt.Level
 	for x := Dim(0); x < edgeLength; x++ {
 		for y := Dim(0); y < edgeLength; y++ {
 			bitPosition := x*edgeLength + y
 			ux := x - edgeLength/2
 			uy := y - edgeLength/2
-			//fmt.Println("Get", bitPosition, "random", randomNumber.Bit(int(bitPosition)), "tree", qt.cell(ux, uy))
-			assert.Equal(t, randomNumber.Bit(int(bitPosition)), uint(qt.Cell(ux, uy)), "at position %d", bitPosition)
+			assert.Equal(t, pattern[bitPosition], qt.Cell(ux, uy) != 0, "at position %d", bitPosition)
 		}
 	}
 }
@@ -1,14 +1,33 @@
 package quadtree
 
 import (
+	"math/rand"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 /*
  * test for random pattern helper funcitons
  */
 func TestRandomPattern(t *testing.T) {
-	qt, randomNumber := treeWithRandomPattern(5)
+	qt, pattern := treeWithRandomPattern(5)
 	treeCorrectness(t, qt)
-	qt.assertRandomPattern(t, randomNumber)
+	qt.assertRandomPattern(t, pattern)
+
+	// levels whose cell count doesn't fit into a machine word
+	qt, pattern = treeWithRandomPattern(7)
+	qt.assertRandomPattern(t, pattern)
+
+	qt = EmptyTree(4).fillTreeWithPattern(-4, 4, randomPattern(64, 1, rand.New(rand.NewSource(1))))
+	assert.Equal(t, Dim(64), qt.Population)
+	assert.Equal(t, Dim(0), qt.fillTreeWithPattern(-4, 4, randomPattern(64, 0, rand.New(rand.NewSource(1)))).Population)
+
+	// the same seed gives the same pattern
+	a := EmptyTree(4).fillTreeWithPattern(-8, 8, randomPattern(256, 0.3, rand.New(rand.NewSource(7))))
+	b := EmptyTree(4).fillTreeWithPattern(-8, 8, randomPattern(256, 0.3, rand.New(rand.NewSource(7))))
+	assert.True(t, a == b)
+
+	qt = EmptyTree(4).FillTreeWithRandomPattern(-4, 4)
+	assert.Equal(t, qt.Population, qt.PopulationIn(-4, -4, 3, 3))
 }
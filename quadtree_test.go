@@ -1,8 +1,16 @@
 package quadtree
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -18,6 +26,220 @@ func TestEmptyTree(t *testing.T) {
 	treeCorrectness(t, qt)
 }
 
+func TestFullTree(t *testing.T) {
+	assert.True(t, liveLeaf == FullTree(0))
+	for level := uint(1); level < 20; level++ {
+		qt := FullTree(level)
+		assert.Equal(t, level, qt.Level)
+		assert.Equal(t, Dim(1)<<(2*level), qt.Population)
+	}
+	assert.True(t, FullTree(12) == fullTrees[conwaySpace][12])
+
+	// within the tree a full block dies from overpopulation, only its corners survive
+	next := FullTree(4).NextGen()
+	assert.Equal(t, Dim(4), next.Population)
+	assert.Equal(t, Dim(1), next.Cell(-8, -8))
+	assert.Equal(t, Dim(1), next.Cell(7, 7))
+}
+
+func TestMaxLevel(t *testing.T) {
+	bits := uint(8 * unsafe.Sizeof(Dim(0)))
+	assert.Equal(t, bits-1, MaxLevel)
+	// the corners of the biggest tree use all but the top bit of Dim
+	edge := Dim(1) << (bits - 2)
+	assert.Equal(t, -edge, EmptyTree(MaxLevel).origin())
+	assert.True(t, EmptyTree(MaxLevel).contains(edge-1, -edge))
+	assert.False(t, EmptyTree(MaxLevel).contains(edge, 0))
+
+	assert.Equal(t, MaxLevel, EmptyTree(MaxLevel-1).grow().Level)
+	assert.Panics(t, func() { EmptyTree(MaxLevel).grow() })
+}
+
+func TestPopulationBig(t *testing.T) {
+	assert.Equal(t, Dim(1)<<62, FullTree(31).Population)
+	assert.Equal(t, big.NewInt(1<<62), FullTree(31).PopulationBig())
+
+	full := FullTree(40)
+	assert.Equal(t, MaxPopulation, full.Population)
+	assert.Equal(t, new(big.Int).Lsh(big.NewInt(1), 80), full.PopulationBig())
+	assert.Equal(t, MaxPopulation, full.grow().Population)
+	assert.Equal(t, new(big.Int).Lsh(big.NewInt(1), 80), full.grow().PopulationBig())
+
+	// a saturated quadrant next to a partially filled one
+	qt := NewTree(Childs{SE: FullTree(32), SW: FullTree(32), NW: EmptyTree(32), NE: EmptyTree(32).SetCell(0, 0, 1)})
+	expect := new(big.Int).Lsh(big.NewInt(1), 65)
+	assert.Equal(t, expect.Add(expect, big.NewInt(1)), qt.PopulationBig())
+	assert.Equal(t, big.NewInt(3), blinker().PopulationBig())
+}
+
+func TestLeafAccessors(t *testing.T) {
+	assert.Equal(t, Dim(1), LiveLeaf().Population)
+	assert.Equal(t, DeadLeaf(), EmptyTree(0))
+
+	qt := NewTree(Childs{SE: LiveLeaf(), SW: DeadLeaf(), NW: DeadLeaf(), NE: DeadLeaf()})
+	assert.Equal(t, EmptyTree(1).SetCell(0, 0, 1), qt)
+}
+
+func TestLookupNode(t *testing.T) {
+	// a cache of empty trees only, random patterns of other tests may have built any node
+	old := nodeMap
+	defer SetNodeCache(old)
+	SetNodeCache(nil)
+	EmptyTree(3)
+	empty := EmptyTree(2)
+	qt, ok := LookupNode(Childs{empty, empty, empty, empty})
+	assert.True(t, ok)
+	assert.Equal(t, EmptyTree(3), qt)
+
+	full := NewTree(Childs{liveLeaf, liveLeaf, liveLeaf, liveLeaf})
+	size, hit, miss := len(nodeMap), cacheHit.Load(), cacheMiss.Load()
+	_, ok = LookupNode(Childs{full, empty.SE, full, full})
+	assert.False(t, ok)
+	assert.Equal(t, size, len(nodeMap))
+	assert.Equal(t, hit, cacheHit.Load())
+	assert.Equal(t, miss, cacheMiss.Load())
+}
+
+type countingCache struct {
+	NodeMap
+	puts int
+}
+
+func (c *countingCache) Put(childs Childs, qt *Quadtree) {
+	c.puts++
+	c.NodeMap.Put(childs, qt)
+}
+
+func TestSetNodeCache(t *testing.T) {
+	old := nodeMap
+	defer SetNodeCache(old)
+	cache := &countingCache{NodeMap: make(NodeMap)}
+	SetNodeCache(cache)
+	assert.Nil(t, nodeMap)
+
+	qt := EmptyTree(4)
+	assert.Equal(t, 4, cache.puts)
+	cached, ok := LookupNode(qt.Childs)
+	assert.True(t, ok)
+	assert.True(t, qt == cached)
+	assert.True(t, qt == NewTree(qt.Childs))
+	assert.Equal(t, 4, cache.puts)
+	_, ok = old.Get(qt.Childs)
+	assert.False(t, ok)
+}
+
+func TestNewTreeConcurrent(t *testing.T) {
+	cells := randomCells(2000, 256)
+	trees := make([]*Quadtree, 8)
+	var wg sync.WaitGroup
+	for i := range trees {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			trees[i] = EmptyTree(1).SetCells(cells)
+		}(i)
+	}
+	wg.Wait()
+	// all goroutines end up with the same cached nodes
+	for _, qt := range trees {
+		assert.True(t, qt == trees[0])
+	}
+	assert.True(t, trees[0] == EmptyTree(1).SetCells(cells))
+}
+
+func TestSetCacheLimit(t *testing.T) {
+	defer SetCacheLimit(DefaultCacheLimit)
+	glider := EmptyTree(4).SetCell(0, -1, 1).SetCell(1, 0, 1).SetCell(-1, 1, 1).SetCell(0, 1, 1).SetCell(1, 1, 1)
+	glider.NextGen()
+	assert.Equal(t, len(nodeMap), CacheLen())
+
+	limit := CacheLen() - 1
+	SetCacheLimit(limit)
+	next := glider.NextGen()
+	// nodes were evicted before the step, the nodes of the stepped tree are kept
+	assert.True(t, CacheLen() < limit)
+	cached, ok := LookupNode(glider.Childs)
+	assert.True(t, ok)
+	assert.True(t, cached == glider)
+	assert.Equal(t, Dim(5), next.Population)
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	defer SetCacheLimit(DefaultCacheLimit)
+	old, _ := treeWithRandomPattern(5)
+	qt := blinker().GrowToFit(20, 20)
+	for i := 0; i < 3; i++ {
+		qt.NextGen()
+	}
+	recent := qt.SetCell(-20, -20, 1)
+	evicted := cacheEvicted.Load()
+
+	// everything but the current epoch has to go
+	SetCacheLimit(1)
+	next := qt.NextGen()
+	assert.True(t, cacheEvicted.Load() > evicted)
+	assert.Contains(t, qt.Stats(), fmt.Sprintln("Cache Evicted:", cacheEvicted.Load()))
+	// the random tree wasn't used since, the other trees were built or stepped in the last epoch
+	_, ok := LookupNode(old.Childs)
+	assert.False(t, ok)
+	for _, node := range []*Quadtree{qt, qt.NW, recent, recent.NW.NW} {
+		cached, ok := LookupNode(node.Childs)
+		assert.True(t, ok)
+		assert.True(t, cached == node)
+	}
+	// the evicted nodes of the result were rebuilt by BruteForceStep, so only their cells are the same
+	assert.True(t, equalNodes(BruteForceStep(qt), next))
+}
+
+func TestPin(t *testing.T) {
+	defer SetCacheLimit(DefaultCacheLimit)
+	old := nodeMap
+	defer SetNodeCache(old)
+	snapshot := glider().GrowToFit(30, 30).SetCell(-30, 20, 1)
+	Pin(snapshot)
+	Pin(snapshot)
+	defer Unpin(snapshot)
+	cached := func() bool {
+		node, ok := LookupNode(snapshot.NW.Childs)
+		return ok && node == snapshot.NW
+	}
+
+	// everything but the current epoch and pinned trees is evicted
+	SetCacheLimit(1)
+	blinker().NextGen()
+	assert.True(t, cached())
+	CompactCache()
+	assert.True(t, cached())
+	SetNodeCache(nil)
+	assert.True(t, cached())
+	assert.True(t, snapshot == glider().GrowToFit(30, 30).SetCell(-30, 20, 1))
+
+	// still pinned once
+	Unpin(snapshot)
+	CompactCache()
+	assert.True(t, cached())
+
+	Unpin(snapshot)
+	CompactCache()
+	assert.False(t, cached())
+	Pin(snapshot)
+}
+
+func TestEmptyTreeMemoized(t *testing.T) {
+	qt := EmptyTree(20)
+	assert.True(t, qt == emptyTrees[conwaySpace][20])
+	assert.True(t, qt == EmptyTree(20))
+	assert.True(t, EmptyTree(19) == qt.NW)
+	assert.Equal(t, Dim(0), qt.Population)
+
+	// compaction resets the memo, so no empty tree outside of the cache is handed out
+	CompactCache(EmptyTree(3))
+	assert.Nil(t, emptyTrees)
+	empty := EmptyTree(2)
+	cached, _ := LookupNode(Childs{empty, empty, empty, empty})
+	assert.True(t, EmptyTree(3) == cached)
+}
+
 func TestGrowToFit(t *testing.T) {
 	qt := EmptyTree(1)
 	qt = qt.GrowToFit(63, 63)
@@ -34,6 +256,74 @@ func TestSetCellPanic(t *testing.T) {
 	assert.Panics(t, func() { qt.SetCell(8, 8, 1) })
 }
 
+func TestSetCells(t *testing.T) {
+	cells := randomCells(1000, 4096)
+	// the same cell twice, the last value wins
+	cells = append(cells, struct{ X, Y, V Dim }{5, 5, 1}, struct{ X, Y, V Dim }{5, 5, 0})
+	expect := EmptyTree(12)
+	for _, c := range cells {
+		expect = expect.SetCell(c.X, c.Y, c.V)
+	}
+	before := append([]struct{ X, Y, V Dim }(nil), cells...)
+	assert.True(t, expect == EmptyTree(12).SetCells(cells))
+	assert.Equal(t, before, cells)
+	assert.Equal(t, Dim(0), expect.Cell(5, 5))
+
+	assert.True(t, blinker() == blinker().SetCells(nil))
+	// the tree grows instead of panicking
+	qt := EmptyTree(3).SetCells([]struct{ X, Y, V Dim }{{4, 0, 1}, {-3000, 7, 1}})
+	assert.Equal(t, Dim(2), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(4, 0))
+	assert.Equal(t, Dim(1), qt.Cell(-3000, 7))
+}
+
+func TestSetCellTracked(t *testing.T) {
+	qt, dirty := EmptyTree(3).SetCellTracked(2, -3, 1)
+	assert.Equal(t, Dim(1), qt.Cell(2, -3))
+	assert.Equal(t, Rect{2, -3, 2, -3}, dirty)
+
+	same, dirty := qt.SetCellTracked(2, -3, 1)
+	assert.True(t, same == qt)
+	assert.True(t, dirty.Empty())
+
+	qt, dirty = qt.SetCellTracked(2, -3, 0)
+	assert.True(t, qt.IsEmpty())
+	assert.Equal(t, Rect{2, -3, 2, -3}, dirty)
+}
+
+func TestTrack(t *testing.T) {
+	qt, dirty := EmptyTree(4).Track(func(qt *Quadtree) *Quadtree {
+		return qt.FillRegion(-3, -2, 4, 5, 1)
+	})
+	assert.Equal(t, Dim(64), qt.Population)
+	assert.Equal(t, Rect{-3, -2, 4, 5}, dirty)
+
+	// only the cells that weren't alive before count
+	qt, dirty = qt.Track(func(qt *Quadtree) *Quadtree {
+		return qt.FillRegion(-3, -2, 6, 5, 1).SetCells([]struct{ X, Y, V Dim }{{0, 0, 1}, {-8, 7, 1}})
+	})
+	assert.Equal(t, Rect{-8, -2, 6, 7}, dirty)
+
+	// pasting a pattern grows the tree, the dirty region is the pattern
+	qt, dirty = EmptyTree(3).Track(func(qt *Quadtree) *Quadtree {
+		return qt.Overlay(glider(), 20, 30)
+	})
+	assert.True(t, qt.Level > 3)
+	assert.Equal(t, Rect{19, 29, 21, 31}, dirty)
+
+	same, dirty := qt.Track(func(qt *Quadtree) *Quadtree {
+		return qt.ClearRegion(-5, -5, 5, 5)
+	})
+	assert.True(t, same == qt)
+	assert.True(t, dirty.Empty())
+
+	// a cell changing its state is a change as well
+	_, dirty = qt.Track(func(qt *Quadtree) *Quadtree {
+		return qt.SetCellState(20, 31, 2)
+	})
+	assert.Equal(t, Rect{20, 31, 20, 31}, dirty)
+}
+
 func TestSetCell(t *testing.T) {
 	qt := EmptyTree(1)
 	for counter := 0; counter < 10; counter++ {
@@ -53,6 +343,42 @@ func TestSetCell(t *testing.T) {
 	assert.Equal(t, Dim(0), qt.Cell(2, 2))
 }
 
+func TestGrowToFitErr(t *testing.T) {
+	edge := Dim(1) << (MaxLevel - 1)
+	qt, err := EmptyTree(1).GrowToFitErr(edge-1, -edge)
+	assert.NoError(t, err)
+	assert.Equal(t, MaxLevel, qt.Level)
+
+	_, err = EmptyTree(1).GrowToFitErr(edge, 0)
+	assert.Error(t, err)
+	_, err = EmptyTree(1).GrowToFitErr(0, -edge-1)
+	assert.Error(t, err)
+	assert.Panics(t, func() { EmptyTree(1).GrowToFit(0, -edge-1) })
+}
+
+func TestGrowToFitRect(t *testing.T) {
+	qt := EmptyTree(1).GrowToFitRect(-8, -8, 7, 7)
+	assert.Equal(t, uint(4), qt.Level)
+	// 8 is just beyond the positive edge of level 4
+	assert.Equal(t, uint(5), EmptyTree(1).GrowToFitRect(-8, -8, 8, 7).Level)
+	assert.Equal(t, uint(5), EmptyTree(1).GrowToFitRect(-8, -8, 7, 8).Level)
+	assert.Equal(t, uint(5), EmptyTree(1).GrowToFitRect(-9, 0, 0, 0).Level)
+	assert.Equal(t, uint(8), EmptyTree(1).GrowToFitRect(-100, -100, -50, -50).Level)
+	assert.Equal(t, uint(8), EmptyTree(1).GrowToFitRect(50, 10, 127, 100).Level)
+	assert.True(t, qt == qt.GrowToFitRect(-1, -1, 1, 1))
+}
+
+func TestSetCellGrow(t *testing.T) {
+	qt := EmptyTree(2).SetCellGrow(55, -233, 1)
+	assert.Equal(t, uint(9), qt.Level)
+	assert.Equal(t, Dim(1), qt.Cell(55, -233))
+	assert.Equal(t, Dim(1), qt.Population)
+	assert.True(t, qt.SetCell(0, 0, 1) == qt.SetCellGrow(0, 0, 1))
+
+	// SetCell keeps panicking
+	assert.Panics(t, func() { EmptyTree(2).SetCell(55, -233, 1) })
+}
+
 func TestCell(t *testing.T) {
 	qt := EmptyTree(1)
 	qt = qt.GrowToFit(55, 233)
@@ -70,34 +396,77 @@ func TestFindLifeCells(t *testing.T) {
 	qt.FindLifeCells(-(1 << (qt.Level - 1)), -(1 << (qt.Level - 1)), func(x, y Dim) { fmt.Println(x, y) })
 }
 
+func TestLiveCells(t *testing.T) {
+	qt := EmptyTree(1).GrowToFit(55, 233)
+	qt = qt.SetCell(55, 232, 1)
+	qt = qt.SetCell(-3, 233, 1)
+	var cells [][2]Dim
+	qt.LiveCells(func(x, y Dim) { cells = append(cells, [2]Dim{x, y}) })
+	assert.ElementsMatch(t, [][2]Dim{{55, 232}, {-3, 233}}, cells)
+
+	// the origin follows the level
+	cells = nil
+	qt.grow().LiveCells(func(x, y Dim) { cells = append(cells, [2]Dim{x, y}) })
+	assert.ElementsMatch(t, [][2]Dim{{55, 232}, {-3, 233}}, cells)
+}
+
+func TestEachLiveCell(t *testing.T) {
+	qt := blinker().SetCell(2, 2, 1)
+	var cells [][2]Dim
+	origin := qt.origin()
+	qt.EachLiveCell(origin, origin, func(x, y Dim) bool {
+		cells = append(cells, [2]Dim{x, y})
+		return len(cells) < 2
+	})
+	assert.Equal(t, [][2]Dim{{-1, 0}, {0, 0}}, cells)
+
+	// FindLifeCells visits all of them
+	count := 0
+	qt.FindLifeCells(origin, origin, func(x, y Dim) { count++ })
+	assert.Equal(t, 4, count)
+}
+
+func TestIsEmptyIsLeaf(t *testing.T) {
+	assert.True(t, deadLeaf.IsEmpty())
+	assert.True(t, deadLeaf.IsLeaf())
+	assert.False(t, liveLeaf.IsEmpty())
+	assert.True(t, liveLeaf.IsLeaf())
+
+	qt := EmptyTree(3)
+	assert.True(t, qt.IsEmpty())
+	assert.False(t, qt.IsLeaf())
+	qt = qt.SetCell(1, 1, 1)
+	assert.False(t, qt.IsEmpty())
+}
+
 func TestOneGen(t *testing.T) {
 	// dying overpopulation
 	var bitmask uint16 = 0xFFFF
-	assert.Equal(t, int64(0), oneGen(bitmask).Population)
+	assert.Equal(t, int64(0), conwaySpace.oneGen(bitmask).Population)
 
 	// liveless
 	bitmask = 0x0000
-	assert.Equal(t, int64(0), oneGen(bitmask).Population)
+	assert.Equal(t, int64(0), conwaySpace.oneGen(bitmask).Population)
 
 	// 3 live neighbours
 	// 0b0111 0000 0000
 	bitmask = 0x0700
-	assert.Equal(t, int64(1), oneGen(bitmask).Population)
+	assert.Equal(t, int64(1), conwaySpace.oneGen(bitmask).Population)
 
 	// 2 live neighbours and self is live
 	// 0b0011 0010 0000
 	bitmask = 0x0320
-	assert.Equal(t, int64(1), oneGen(bitmask).Population)
+	assert.Equal(t, int64(1), conwaySpace.oneGen(bitmask).Population)
 
 	// 1 live neighbours and self is live
 	// 0b0010 0010 0000
 	bitmask = 0x0220
-	assert.Equal(t, int64(0), oneGen(bitmask).Population)
+	assert.Equal(t, int64(0), conwaySpace.oneGen(bitmask).Population)
 
 	// 3 live neighbours below
 	// 0b0000 0000 0111
 	bitmask = 0x0007
-	assert.Equal(t, int64(1), oneGen(bitmask).Population)
+	assert.Equal(t, int64(1), conwaySpace.oneGen(bitmask).Population)
 }
 
 func TestCenteredSubnode(t *testing.T) {
@@ -164,37 +533,79 @@ func TestSlowSimulation(t *testing.T) {
 	emptyResult := qt.slowSimulation()
 	assert.Equal(t, EmptyTree(1), emptyResult)
 
-	// 1 | 1
-	// 0 | 1
-	qt = EmptyTree(2)
-	qt.SetCell(-1, -1, 1)
-	qt.SetCell(0, -1, 1)
-	qt.SetCell(0, 0, 1)
+	// 0000
+	// 0110
+	// 0010
+	// 0000
+	qt = FromBits(2, []uint64{0x0620})
 
 	fullResult := qt.slowSimulation()
-	expect := EmptyTree(1)
-	expect.SetCell(0, 0, 1)
-	expect.SetCell(-1, 0, 1)
-	expect.SetCell(-1, -1, 1)
-	expect.SetCell(0, -1, 1)
+	expect := FromBits(1, []uint64{0xF})
 	assert.Equal(t, expect, fullResult)
 
 	// next genartion should be full as well
 	fullResult = fullResult.grow().slowSimulation()
 	assert.Equal(t, expect, fullResult)
 
-	// 1 | 1| 1| 1
-	// 1 | 1| 1| 1
-	// 1 | 1| 1| 1
-	// 1 | 1| 1| 1
-	qt = EmptyTree(2)
-	for x := Dim(-2); x < 2; x++ {
+	// 1111
+	// 1111
+	// 1111
+	// 1111
+	qt = FromBits(2, []uint64{0xFFFF})
+	emptyResult2 := qt.slowSimulation()
+	assert.Equal(t, EmptyTree(1), emptyResult2)
+
+	// compare with the bitmask build from cell coordinates
+	for i := 0; i < 100; i++ {
+		qt, _ = treeWithRandomPattern(2)
+		allbits := uint16(0)
 		for y := Dim(-2); y < 2; y++ {
-			qt.SetCell(x, y, 1)
+			for x := Dim(-2); x < 2; x++ {
+				allbits = (allbits << 1) + uint16(qt.Cell(x, y))
+			}
 		}
+		expect = NewTree(Childs{conwaySpace.oneGen(allbits), conwaySpace.oneGen(allbits >> 1), conwaySpace.oneGen(allbits >> 5), conwaySpace.oneGen(allbits >> 4)})
+		assert.Equal(t, expect, qt.slowSimulation())
 	}
-	emptyResult2 := qt.slowSimulation()
-	assert.Equal(t, EmptyTree(1), emptyResult2)
+}
+
+func TestBits4x4(t *testing.T) {
+	for _, bits := range []uint16{0, 0xFFFF, 0x0620, 0x8001, 0x1234} {
+		got, err := FromBits(2, []uint64{uint64(bits)}).Bits4x4()
+		assert.NoError(t, err)
+		assert.Equal(t, bits, got)
+	}
+
+	qt := EmptyTree(2).SetCell(-2, -2, 1).SetCell(1, 1, 1)
+	bits, err := qt.Bits4x4()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x8001), bits)
+
+	_, err = EmptyTree(3).Bits4x4()
+	assert.Error(t, err)
+}
+
+func TestNextGenerationStep(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		qt := EmptyTree(6).SetCells(randomCells(400, 40))
+		for step := uint(0); step <= 4; step++ {
+			expect := qt
+			for gen := 0; gen < 1<<step; gen++ {
+				expect = BruteForceStep(expect)
+			}
+			assert.Equal(t, expect.centeredSubnode(), qt.NextGenerationStep(step), "step %v", step)
+		}
+	}
+
+	// a glider moves one cell diagonally every 4 generations
+	qt := glider().GrowToFit(-32, -32)
+	next := qt.NextGenerationStep(4)
+	assert.Equal(t, Dim(5), next.Population)
+	assert.Equal(t, Dim(1), next.Cell(4, 3))
+	assert.True(t, next == stepCache[stepKey{qt, 4}])
+	assert.True(t, next == qt.NextGenerationStep(4))
+
+	assert.Panics(t, func() { EmptyTree(4).NextGenerationStep(3) })
 }
 
 // trivial case of empty tree
@@ -207,9 +618,254 @@ func TestNextGeneration(t *testing.T) {
 	assert.Equal(t, qt, qtNext)
 }
 
+func TestNextGenerationQuadrant(t *testing.T) {
+	for level := uint(2); level < 5; level++ {
+		qt, _ := treeWithRandomPattern(level)
+		quadrants := []*Quadtree{
+			qt.NextGenerationQuadrant(NorthWest),
+			qt.NextGenerationQuadrant(NorthEast),
+			qt.NextGenerationQuadrant(SouthWest),
+			qt.NextGenerationQuadrant(SouthEast),
+		}
+		next := qt.NextGeneration()
+		assert.Equal(t, []*Quadtree{next.NW, next.NE, next.SW, next.SE}, quadrants)
+		// served from qt.next as well
+		assert.Equal(t, next.SE, qt.NextGenerationQuadrant(SouthEast))
+	}
+	assert.Panics(t, func() { EmptyTree(1).NextGenerationQuadrant(NorthWest) })
+}
+
+func TestCacheStats(t *testing.T) {
+	qt := blinker().NextGen()
+	stats := qt.CacheStats()
+	assert.Equal(t, len(nodeMap), stats.Size)
+	assert.Equal(t, cacheHit.Load(), stats.Hits)
+	assert.Equal(t, cacheMiss.Load(), stats.Misses)
+	assert.InDelta(t, float64(stats.Hits)/float64(stats.Hits+stats.Misses), stats.HitRatio, 1e-9)
+	assert.True(t, stats.Levels[3] > 0)
+	total := 0
+	for _, count := range stats.Levels {
+		total += count
+	}
+	assert.Equal(t, stats.Size, total)
+	assert.Contains(t, qt.Stats(), fmt.Sprintln("Cache Size:", stats.Size))
+}
+
+func TestStatsLevelHistogram(t *testing.T) {
+	old := nodeMap
+	defer SetNodeCache(old)
+	cache := make(NodeMap)
+	SetNodeCache(cache)
+	// one node each of level 1 to 3 and three nodes of level 9, no nodes in between
+	EmptyTree(3)
+	for i := 0; i < 3; i++ {
+		cache[Childs{SE: &Quadtree{}}] = &Quadtree{Level: 9}
+	}
+	s := EmptyTree(3).Stats()
+	assert.True(t, strings.HasSuffix(s, "1 1\n2 1\n3 1\n9 3\n"), s)
+}
+
+func TestStatsNonBlocking(t *testing.T) {
+	qt := blinker().NextGen()
+	assert.Contains(t, qt.StatsNonBlocking(), fmt.Sprintln("Cache Size:", len(nodeMap)))
+	assert.Contains(t, qt.StatsNonBlocking(), fmt.Sprintln("Population:", qt.Population))
+
+	// a step in progress holds the mutex
+	mutex.Lock()
+	defer mutex.Unlock()
+	done := make(chan string)
+	go func() { done <- qt.StatsNonBlocking() }()
+	select {
+	case s := <-done:
+		assert.Contains(t, s, "Cache Hit:")
+	case <-time.After(time.Second):
+		t.Fatal("StatsNonBlocking waited for the mutex")
+	}
+}
+
+func TestFreezeCache(t *testing.T) {
+	qt := blinker().GrowToFit(40, 40)
+	expect := BruteForceStep(qt)
+
+	FreezeCache(true)
+	defer FreezeCache(false)
+	size := len(nodeMap)
+	qt = qt.SetCell(-40, -40, 1).SetCell(-40, -40, 0)
+	next := qt.NextGen()
+	assert.Equal(t, size, len(nodeMap))
+	assert.Equal(t, Dim(3), next.Population)
+	assert.Equal(t, Dim(1), next.Cell(0, 1))
+
+	FreezeCache(false)
+	assert.Equal(t, expect, qt.NextGen())
+}
+
+func TestCacheEntriesByPopulation(t *testing.T) {
+	EmptyTree(6)
+	NewTree(Childs{liveLeaf, deadLeaf, deadLeaf, liveLeaf})
+	entries := CacheEntriesByPopulation()
+	assert.True(t, entries[0] >= 6)
+	assert.True(t, entries[2] >= 1)
+
+	total := 0
+	for _, count := range entries {
+		total += count
+	}
+	assert.Equal(t, len(nodeMap), total)
+}
+
+func TestCompactCache(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		treeWithRandomPattern(4)
+	}
+	qt := blinker().GrowToFit(20, 20)
+	grown := qt.grow()
+	next := grown.NextGeneration()
+	CompactCache(qt, grown)
+
+	cached, ok := LookupNode(qt.Childs)
+	assert.True(t, ok)
+	assert.Equal(t, qt, cached)
+	// the next generation is reachable via next of the grown tree
+	cached, ok = LookupNode(next.Childs)
+	assert.True(t, ok)
+	assert.Equal(t, next, cached)
+
+	reachable := make(map[*Quadtree]struct{})
+	qt.markReachable(reachable)
+	grown.markReachable(reachable)
+	assert.True(t, len(nodeMap) <= len(reachable))
+
+	// rebuilding gives the very same nodes
+	assert.Equal(t, qt, blinker().GrowToFit(20, 20))
+}
+
+func TestNextGenContext(t *testing.T) {
+	qt := blinker().GrowToFit(600, 600).SetCell(-513, 317, 1).SetCell(-512, 317, 1).SetCell(-511, 317, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	next, err := qt.NextGenContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, next)
+
+	next, err = qt.NextGenContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Dim(6), next.Population)
+	assert.Equal(t, Dim(1), next.Cell(-512, 316))
+	assert.True(t, next == qt.NextGen())
+
+	// small trees aren't checked
+	next, err = blinker().NextGenContext(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Dim(1), next.Cell(0, -1))
+}
+
+func TestNextGenReusesCenter(t *testing.T) {
+	for level := uint(3); level < 7; level++ {
+		qt, _ := treeWithRandomPattern(level)
+		// a copy that isn't cached and has no next generation yet
+		copied := &Quadtree{Level: qt.Level, Childs: qt.Childs, Population: qt.Population, space: qt.space}
+		expect := copied.NextGeneration()
+
+		copied = &Quadtree{Level: qt.Level, Childs: qt.Childs, Population: qt.Population, space: qt.space}
+		copied.NextGen()
+		assert.Equal(t, expect, copied.next.Load(), "level %v", level)
+
+		grown := copied.grow()
+		around, err := grown.nextGenerationAround(context.Background(), copied.next.Load())
+		assert.NoError(t, err)
+		assert.Equal(t, grown.NextGeneration(), around, "level %v", level)
+		assert.Equal(t, BruteForceStep(qt), copied.NextGen(), "level %v", level)
+	}
+}
+
+func TestSubnode(t *testing.T) {
+	qt, _ := treeWithRandomPattern(4)
+	for _, c := range [][3]Dim{{4, 0, 0}, {2, 4, 4}, {2, 2, 6}, {3, 5, 1}, {1, 7, 9}, {0, 3, 15}} {
+		sub := qt.subnode(uint(c[0]), c[1], c[2])
+		assert.Equal(t, uint(c[0]), sub.Level)
+		size := Dim(1) << uint(c[0])
+		for y := Dim(0); y < size; y++ {
+			for x := Dim(0); x < size; x++ {
+				expect := qt.Cell(c[1]+x-8, c[2]+y-8)
+				if sub.IsLeaf() {
+					assert.Equal(t, expect, sub.Population)
+				} else {
+					assert.Equal(t, expect, sub.Cell(x-size/2, y-size/2))
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentBuildAndStep(t *testing.T) {
+	soup := RandomSoup(6, 0.3, 5)
+	expect := soup.NextGen().NextGen()
+	var wg sync.WaitGroup
+	results := make([]*Quadtree, 4)
+	for g := range results {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			// NextGen and NextGeneration on shared nodes
+			qt := soup
+			for i := 0; i < 2; i++ {
+				qt.grow().NextGeneration()
+				qt = qt.NextGen()
+			}
+			results[g] = qt
+		}(g)
+		go func(g int) {
+			defer wg.Done()
+			qt := EmptyTree(6)
+			for i := Dim(0); i < 100; i++ {
+				qt = qt.SetCell(i%32-Dim(g), i/8, 1)
+				NewTree(Childs{qt.NW, qt.NW, qt.SE, qt.SE})
+			}
+		}(g)
+	}
+	wg.Wait()
+	for _, qt := range results {
+		assert.True(t, expect == qt)
+	}
+}
+
 func TestString(t *testing.T) {
 	qt, _ := treeWithRandomPattern(3)
-	fmt.Sprint(qt)
+	_ = fmt.Sprint(qt) // go vet rejects unused results of fmt.Sprint
+}
+
+func TestPrintWith(t *testing.T) {
+	qt := EmptyTree(2)
+	qt = qt.SetCell(-1, 0, 1)
+	qt = qt.SetCell(1, 1, 1)
+
+	var buf bytes.Buffer
+	qt.PrintWith(PrintOptions{Output: &buf, Live: "O", Dead: "."})
+	assert.Equal(t, "....\n....\n.O..\n...O\n", buf.String())
+
+	buf.Reset()
+	qt.PrintWith(PrintOptions{Output: &buf, Coordinates: true, Live: "1", Dead: "0", Separator: " "})
+	assert.Equal(t, "-2: 0 0 0 0\n-1: 0 0 0 0\n 0: 0 1 0 0\n 1: 0 0 0 1\n", buf.String())
+
+	// the gutter is as wide as the smallest y coordinate
+	buf.Reset()
+	EmptyTree(8).PrintWith(PrintOptions{Output: &buf, Coordinates: true, Dead: "."})
+	lines := strings.Split(buf.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], "-128: ."))
+	assert.True(t, strings.HasPrefix(lines[128], "   0: ."))
+}
+
+func TestPrintWithViewport(t *testing.T) {
+	qt := blinker()
+	var buf bytes.Buffer
+	// the viewport reaches beyond the tree
+	qt.PrintWith(PrintOptions{Output: &buf, Coordinates: true, Live: "O", Dead: ".", Viewport: &Viewport{-2, -1, 3, 12}})
+	lines := strings.Split(buf.String(), "\n")
+	assert.Len(t, lines, 13)
+	assert.Equal(t, "-1: ...", lines[0])
+	assert.Equal(t, " 0: .OO", lines[1])
+	assert.Equal(t, "10: ...", lines[11])
 }
 
 /*
@@ -238,6 +894,46 @@ func benchmarkGrowToFit(size Dim, b *testing.B) {
 	}
 }
 
+func BenchmarkSlowSimulation(b *testing.B) {
+	qt, _ := treeWithRandomPattern(2)
+	for n := 0; n < b.N; n++ {
+		qt.slowSimulation()
+	}
+}
+
+func BenchmarkEmptyTree32(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		EmptyTree(32)
+	}
+}
+
+// randomCells returns n reproducible random cells within a size x size square at the origin
+func randomCells(n int, size Dim) []struct{ X, Y, V Dim } {
+	r := rand.New(rand.NewSource(42))
+	cells := make([]struct{ X, Y, V Dim }, n)
+	for i := range cells {
+		cells[i].X, cells[i].Y, cells[i].V = r.Int63n(size)-size/2, r.Int63n(size)-size/2, r.Int63n(2)
+	}
+	return cells
+}
+
+func BenchmarkSetCells10k(b *testing.B) {
+	cells := randomCells(10000, 128)
+	for n := 0; n < b.N; n++ {
+		EmptyTree(12).SetCells(cells)
+	}
+}
+
+func BenchmarkSetCellLoop10k(b *testing.B) {
+	cells := randomCells(10000, 128)
+	for n := 0; n < b.N; n++ {
+		qt := EmptyTree(12)
+		for _, c := range cells {
+			qt = qt.SetCell(c.X, c.Y, c.V)
+		}
+	}
+}
+
 func BenchmarkGrowToFit3(b *testing.B)  { benchmarkGrowToFit(Dim(1)<<3, b) }
 func BenchmarkGrowToFit8(b *testing.B)  { benchmarkGrowToFit(Dim(1)<<8, b) }
 func BenchmarkGrowToFit16(b *testing.B) { benchmarkGrowToFit(Dim(1)<<16, b) }
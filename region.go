@@ -0,0 +1,398 @@
+package quadtree
+
+// origin returns the global coordinates of the north west corner of the root qt
+func (qt *Quadtree) origin() Dim {
+	return -(Dim(1) << (qt.Level - 1))
+}
+
+// bounds returns the coordinate range of the root qt
+func (qt *Quadtree) bounds() Rect {
+	origin := qt.origin()
+	return Rect{origin, origin, -origin - 1, -origin - 1}
+}
+
+// overlap tells how the node of qt with north west corner (x,y) relates to r
+func (qt *Quadtree) overlap(x, y Dim, r Rect) (disjoint, contained bool) {
+	last := Dim(1)<<qt.Level - 1
+	disjoint = x > r.MaxX || y > r.MaxY || x+last < r.MinX || y+last < r.MinY
+	contained = x >= r.MinX && y >= r.MinY && x+last <= r.MaxX && y+last <= r.MaxY
+	return
+}
+
+// RegionEmpty reports whether no live cell lies within the rectangle. Min and max are inclusive.
+// It returns as soon as it finds any live cell.
+func (qt *Quadtree) RegionEmpty(minX, minY, maxX, maxY Dim) bool {
+	origin := qt.origin()
+	return qt.regionEmpty(origin, origin, Rect{minX, minY, maxX, maxY})
+}
+
+func (qt *Quadtree) regionEmpty(x, y Dim, r Rect) bool {
+	if qt.IsEmpty() {
+		return true
+	}
+	disjoint, contained := qt.overlap(x, y, r)
+	if disjoint {
+		return true
+	}
+	if contained {
+		return false
+	}
+	half := Dim(1) << (qt.Level - 1)
+	return qt.NW.regionEmpty(x, y, r) &&
+		qt.NE.regionEmpty(x+half, y, r) &&
+		qt.SW.regionEmpty(x, y+half, r) &&
+		qt.SE.regionEmpty(x+half, y+half, r)
+}
+
+// BoundingBox returns the smallest rectangle containing all live cells of qt, empty is true if there are none.
+// Only subtrees with live cells are descended and shared subtrees are visited once, so it's fast on large sparse universes.
+func (qt *Quadtree) BoundingBox() (minX, minY, maxX, maxY Dim, empty bool) {
+	box, ok := qt.boundingBox()
+	return box.MinX, box.MinY, box.MaxX, box.MaxY, !ok
+}
+
+// boundingBox returns the smallest rectangle containing all live cells of the root qt. ok is false if qt is empty.
+func (qt *Quadtree) boundingBox() (box Rect, ok bool) {
+	if qt.IsEmpty() {
+		return Rect{}, false
+	}
+	box = qt.localBounds(make(map[*Quadtree]Rect))
+	origin := qt.origin()
+	return Rect{box.MinX + origin, box.MinY + origin, box.MaxX + origin, box.MaxY + origin}, true
+}
+
+// localBounds returns the bounding box of the live cells of the non empty qt relative to its north west corner.
+// Results are memoized per node, so shared subtrees are only visited once.
+func (qt *Quadtree) localBounds(memo map[*Quadtree]Rect) Rect {
+	if qt.IsLeaf() {
+		return Rect{}
+	}
+	if box, ok := memo[qt]; ok {
+		return box
+	}
+	half := Dim(1) << (qt.Level - 1)
+	var box Rect
+	found := false
+	for _, c := range []struct {
+		child  *Quadtree
+		dx, dy Dim
+	}{{qt.NW, 0, 0}, {qt.NE, half, 0}, {qt.SW, 0, half}, {qt.SE, half, half}} {
+		if c.child.IsEmpty() {
+			continue
+		}
+		childBox := c.child.localBounds(memo)
+		childBox = Rect{childBox.MinX + c.dx, childBox.MinY + c.dy, childBox.MaxX + c.dx, childBox.MaxY + c.dy}
+		if !found {
+			box = childBox
+			found = true
+			continue
+		}
+		box = box.union(childBox)
+	}
+	memo[qt] = box
+	return box
+}
+
+// LiveFitsInLevel reports whether all live cells of qt lie within the coordinate range of a tree of the given level
+func (qt *Quadtree) LiveFitsInLevel(level uint) bool {
+	box, ok := qt.boundingBox()
+	if !ok {
+		return true
+	}
+	return levelContains(level, box.MinX, box.MinY) && levelContains(level, box.MaxX, box.MaxY)
+}
+
+// union returns the smallest rectangle containing r and o
+func (r Rect) union(o Rect) Rect {
+	if o.MinX < r.MinX {
+		r.MinX = o.MinX
+	}
+	if o.MinY < r.MinY {
+		r.MinY = o.MinY
+	}
+	if o.MaxX > r.MaxX {
+		r.MaxX = o.MaxX
+	}
+	if o.MaxY > r.MaxY {
+		r.MaxY = o.MaxY
+	}
+	return r
+}
+
+// intersect returns the cells contained in both r and o, an empty Rect if there are none
+func (r Rect) intersect(o Rect) Rect {
+	if o.MinX > r.MinX {
+		r.MinX = o.MinX
+	}
+	if o.MinY > r.MinY {
+		r.MinY = o.MinY
+	}
+	if o.MaxX < r.MaxX {
+		r.MaxX = o.MaxX
+	}
+	if o.MaxY < r.MaxY {
+		r.MaxY = o.MaxY
+	}
+	return r
+}
+
+// eachLiveIn calls fn for every live cell of the root qt within r. Only subtrees overlapping r are visited.
+func (qt *Quadtree) eachLiveIn(r Rect, fn func(x, y Dim)) {
+	origin := qt.origin()
+	qt.eachLiveInNode(origin, origin, r, fn)
+}
+
+func (qt *Quadtree) eachLiveInNode(x, y Dim, r Rect, fn func(x, y Dim)) {
+	if qt.IsEmpty() {
+		return
+	}
+	if disjoint, _ := qt.overlap(x, y, r); disjoint {
+		return
+	}
+	if qt.IsLeaf() {
+		fn(x, y)
+		return
+	}
+	half := Dim(1) << (qt.Level - 1)
+	qt.NW.eachLiveInNode(x, y, r, fn)
+	qt.NE.eachLiveInNode(x+half, y, r, fn)
+	qt.SW.eachLiveInNode(x, y+half, r, fn)
+	qt.SE.eachLiveInNode(x+half, y+half, r, fn)
+}
+
+// liveGrid returns the state of the cells within r row by row
+func (qt *Quadtree) liveGrid(r Rect) [][]bool {
+	grid := make([][]bool, r.MaxY-r.MinY+1)
+	for i := range grid {
+		grid[i] = make([]bool, r.MaxX-r.MinX+1)
+	}
+	qt.eachLiveIn(r, func(x, y Dim) {
+		grid[y-r.MinY][x-r.MinX] = true
+	})
+	return grid
+}
+
+// PopulationIn returns the number of live cells within the rectangle. Min and max are inclusive.
+// Subtrees contained in the rectangle contribute their population without being descended, disjoint ones are skipped.
+func (qt *Quadtree) PopulationIn(minX, minY, maxX, maxY Dim) Dim {
+	origin := qt.origin()
+	return qt.populationIn(origin, origin, Rect{minX, minY, maxX, maxY})
+}
+
+func (qt *Quadtree) populationIn(x, y Dim, r Rect) Dim {
+	if qt.IsEmpty() {
+		return 0
+	}
+	disjoint, contained := qt.overlap(x, y, r)
+	if disjoint {
+		return 0
+	}
+	if contained {
+		return qt.Population
+	}
+	half := Dim(1) << (qt.Level - 1)
+	return qt.NW.populationIn(x, y, r) +
+		qt.NE.populationIn(x+half, y, r) +
+		qt.SW.populationIn(x, y+half, r) +
+		qt.SE.populationIn(x+half, y+half, r)
+}
+
+// ClearRegion returns qt with all cells within the rectangle dead. Min and max are inclusive.
+// Subtrees contained in the rectangle are replaced by the empty tree of their level without being descended,
+// subtrees without live cells in it are kept. qt itself is returned if the region is already empty.
+func (qt *Quadtree) ClearRegion(minX, minY, maxX, maxY Dim) *Quadtree {
+	origin := qt.origin()
+	return qt.setRegion(origin, origin, Rect{minX, minY, maxX, maxY}, qt.space.dead)
+}
+
+// FillRegion returns qt with all cells within the rectangle set to value, 0 for dead, anything else for alive.
+// Min and max are inclusive. Subtrees contained in the rectangle are replaced by the cached empty or fully live
+// tree of their level without being descended, so the cost depends on the perimeter of the rectangle, not on its area.
+func (qt *Quadtree) FillRegion(minX, minY, maxX, maxY Dim, value Dim) *Quadtree {
+	leaf := qt.space.live
+	if value == 0 {
+		leaf = qt.space.dead
+	}
+	origin := qt.origin()
+	return qt.setRegion(origin, origin, Rect{minX, minY, maxX, maxY}, leaf)
+}
+
+// setRegion returns the node of qt with north west corner (x,y) with all cells within r set to the state of leaf
+func (qt *Quadtree) setRegion(x, y Dim, r Rect, leaf *Quadtree) *Quadtree {
+	if qt.isUniform(leaf) {
+		return qt
+	}
+	disjoint, contained := qt.overlap(x, y, r)
+	if disjoint {
+		return qt
+	}
+	if contained {
+		if leaf.IsEmpty() {
+			return qt.space.emptyTree(qt.Level)
+		}
+		return qt.space.fullTree(qt.Level)
+	}
+	half := Dim(1) << (qt.Level - 1)
+	childs := Childs{
+		NW: qt.NW.setRegion(x, y, r, leaf),
+		NE: qt.NE.setRegion(x+half, y, r, leaf),
+		SW: qt.SW.setRegion(x, y+half, r, leaf),
+		SE: qt.SE.setRegion(x+half, y+half, r, leaf),
+	}
+	if childs == qt.Childs {
+		return qt
+	}
+	return NewTree(childs)
+}
+
+// isUniform reports whether all cells of qt have the state of leaf
+func (qt *Quadtree) isUniform(leaf *Quadtree) bool {
+	if leaf.IsEmpty() {
+		return qt.IsEmpty()
+	}
+	return qt.isFull()
+}
+
+// isFull reports whether all cells of qt are alive. Above level 31 the population saturates, so only trees
+// built like FullTree from four identical childs are recognized, for others it errs on the side of false.
+func (qt *Quadtree) isFull() bool {
+	if qt.Population < MaxPopulation {
+		size := Dim(1) << qt.Level
+		return qt.Level <= MaxLevel/2 && qt.Population == size*size
+	}
+	return qt.NW == qt.NE && qt.NW == qt.SW && qt.NW == qt.SE && qt.NW.isFull()
+}
+
+// PopulationParity returns the parity (0 or 1) of the number of live cells within the rectangle. Min and max are inclusive.
+// The parities of the subtrees are combined by XOR, so it stays exact where the population saturates at MaxPopulation.
+// Subtrees contained in the rectangle contribute their parity without being descended, disjoint ones are skipped.
+func (qt *Quadtree) PopulationParity(minX, minY, maxX, maxY Dim) int {
+	origin := qt.origin()
+	return qt.populationParity(origin, origin, Rect{minX, minY, maxX, maxY})
+}
+
+func (qt *Quadtree) populationParity(x, y Dim, r Rect) int {
+	if qt.IsEmpty() {
+		return 0
+	}
+	disjoint, contained := qt.overlap(x, y, r)
+	if disjoint {
+		return 0
+	}
+	if contained && qt.Population < MaxPopulation {
+		return int(qt.Population & 1)
+	}
+	if contained && qt.isFull() {
+		return 0 // 4^level cells, the leaves aren't saturated
+	}
+	half := Dim(1) << (qt.Level - 1)
+	return qt.NW.populationParity(x, y, r) ^
+		qt.NE.populationParity(x+half, y, r) ^
+		qt.SW.populationParity(x, y+half, r) ^
+		qt.SE.populationParity(x+half, y+half, r)
+}
+
+// Span is a horizontal run of live cells from Start to End (inclusive)
+type Span struct {
+	Start, End Dim
+}
+
+// RowSpans returns for each row between minY and maxY (inclusive) the runs of live cells ordered by x.
+// Rows without live cells are omitted. Completely live subtrees contribute one span per row without being descended.
+func (qt *Quadtree) RowSpans(minY, maxY Dim) map[Dim][]Span {
+	spans := make(map[Dim][]Span)
+	origin := qt.origin()
+	r := Rect{origin, minY, -origin - 1, maxY}
+	qt.rowSpans(origin, origin, r, spans)
+	return spans
+}
+
+func (qt *Quadtree) rowSpans(x, y Dim, r Rect, spans map[Dim][]Span) {
+	if qt.IsEmpty() {
+		return
+	}
+	if disjoint, _ := qt.overlap(x, y, r); disjoint {
+		return
+	}
+	size := Dim(1) << qt.Level
+	if qt.isFull() {
+		for row := y; row < y+size; row++ {
+			if row >= r.MinY && row <= r.MaxY {
+				spans[row] = appendSpan(spans[row], Span{x, x + size - 1})
+			}
+		}
+		return
+	}
+	// west before east, so the spans of each row are appended in x order
+	half := size / 2
+	qt.NW.rowSpans(x, y, r, spans)
+	qt.NE.rowSpans(x+half, y, r, spans)
+	qt.SW.rowSpans(x, y+half, r, spans)
+	qt.SE.rowSpans(x+half, y+half, r, spans)
+}
+
+// appendSpan appends s to spans, merging it with the last span if they touch
+func appendSpan(spans []Span, s Span) []Span {
+	if last := len(spans) - 1; last >= 0 && spans[last].End+1 == s.Start {
+		spans[last].End = s.End
+		return spans
+	}
+	return append(spans, s)
+}
+
+// stripNode is a node of a horizontal strip with the x coordinate of its west border
+type stripNode struct {
+	qt *Quadtree
+	x  Dim
+}
+
+// FindLifeRuns calls fn for every run of live cells in row-major order: rows from north to south and
+// the runs of each row from west to east. The tree is traversed one strip of rows at a time, so memory
+// is bounded by the width of the pattern and not by its population.
+func (qt *Quadtree) FindLifeRuns(fn func(y Dim, s Span)) {
+	if qt.IsEmpty() {
+		return
+	}
+	origin := qt.origin()
+	buffers := make([][]stripNode, qt.Level+1) // one reused strip per level
+	findLifeRuns([]stripNode{{qt, origin}}, qt.Level, origin, buffers, fn)
+}
+
+// findLifeRuns emits the runs of the non empty nodes of strip, which all have the given level, are ordered by x
+// and cover the rows starting at y
+func findLifeRuns(strip []stripNode, level uint, y Dim, buffers [][]stripNode, fn func(y Dim, s Span)) {
+	if level == 0 {
+		run := Span{strip[0].x, strip[0].x}
+		for _, n := range strip[1:] {
+			if n.x == run.End+1 {
+				run.End = n.x
+				continue
+			}
+			fn(y, run)
+			run = Span{n.x, n.x}
+		}
+		fn(y, run)
+		return
+	}
+	// the north halves of all nodes before the south halves, west before east
+	half := Dim(1) << (level - 1)
+	for i := Dim(0); i < 2; i++ {
+		next := buffers[level-1][:0]
+		for _, n := range strip {
+			west, east := n.qt.NW, n.qt.NE
+			if i == 1 {
+				west, east = n.qt.SW, n.qt.SE
+			}
+			if !west.IsEmpty() {
+				next = append(next, stripNode{west, n.x})
+			}
+			if !east.IsEmpty() {
+				next = append(next, stripNode{east, n.x + half})
+			}
+		}
+		buffers[level-1] = next
+		if len(next) > 0 {
+			findLifeRuns(next, level-1, y+i*half, buffers, fn)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package quadtree
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionEmpty(t *testing.T) {
+	qt := treeWithCells([2]Dim{5, -7}, [2]Dim{-20, 3})
+
+	assert.False(t, qt.RegionEmpty(5, -7, 5, -7))
+	assert.False(t, qt.RegionEmpty(-100, -100, 100, 100))
+	assert.False(t, qt.RegionEmpty(-20, 0, -10, 10))
+	assert.True(t, qt.RegionEmpty(6, -7, 10, 10))
+	assert.True(t, qt.RegionEmpty(-19, -100, 4, 100))
+	// empty rectangle
+	assert.True(t, qt.RegionEmpty(5, -7, 4, -7))
+
+	assert.True(t, EmptyTree(10).RegionEmpty(-100, -100, 100, 100))
+}
+
+func TestBoundingBoxInternal(t *testing.T) {
+	_, ok := EmptyTree(5).boundingBox()
+	assert.False(t, ok)
+
+	qt := treeWithCells([2]Dim{5, -7}, [2]Dim{-20, 3}, [2]Dim{0, 0})
+	box, ok := qt.boundingBox()
+	assert.True(t, ok)
+	assert.Equal(t, Rect{-20, -7, 5, 3}, box)
+
+	box, _ = treeWithCells([2]Dim{-1, -1}).boundingBox()
+	assert.Equal(t, Rect{-1, -1, -1, -1}, box)
+}
+
+func TestBoundingBox(t *testing.T) {
+	_, _, _, _, empty := EmptyTree(40).BoundingBox()
+	assert.True(t, empty)
+
+	// two cells far apart in a huge universe
+	qt := treeWithCells([2]Dim{1 << 40, -3}, [2]Dim{-5, -(1 << 35)})
+	minX, minY, maxX, maxY, empty := qt.BoundingBox()
+	assert.False(t, empty)
+	assert.Equal(t, []Dim{-5, -(1 << 35), 1 << 40, -3}, []Dim{minX, minY, maxX, maxY})
+}
+
+func TestLiveFitsInLevel(t *testing.T) {
+	qt := treeWithCells([2]Dim{3, -4})
+	assert.True(t, qt.LiveFitsInLevel(3))
+	assert.False(t, qt.LiveFitsInLevel(2))
+
+	// asymmetric range: 4 doesn't fit in level 3
+	qt = treeWithCells([2]Dim{-4, 4})
+	assert.False(t, qt.LiveFitsInLevel(3))
+	assert.True(t, qt.LiveFitsInLevel(4))
+
+	assert.True(t, EmptyTree(8).LiveFitsInLevel(1))
+}
+
+func TestPopulationParity(t *testing.T) {
+	qt := treeWithCells([2]Dim{5, -7}, [2]Dim{-20, 3}, [2]Dim{0, 0})
+	assert.Equal(t, 1, qt.PopulationParity(-100, -100, 100, 100))
+	assert.Equal(t, 0, qt.PopulationParity(0, -7, 5, 0))
+	assert.Equal(t, 1, qt.PopulationParity(0, -7, 5, -1))
+	assert.Equal(t, 0, qt.PopulationParity(1, 1, 100, 100))
+
+	for i := 0; i < 10; i++ {
+		qt, _ = treeWithRandomPattern(4)
+		count := 0
+		qt.eachLiveIn(Rect{-3, -5, 6, 2}, func(x, y Dim) { count++ })
+		assert.Equal(t, count%2, qt.PopulationParity(-3, -5, 6, 2))
+	}
+
+	// saturated populations above level 31
+	for _, level := range []uint{32, 33, 50} {
+		full := FullTree(level)
+		bounds := full.bounds()
+		assert.Equal(t, 0, full.PopulationParity(bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY), "level %v", level)
+		assert.Equal(t, 1, full.SetCell(5, -3, 0).PopulationParity(bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY),
+			"level %v", level)
+		assert.Equal(t, 1, full.PopulationParity(0, 0, 2, 0), "level %v", level)
+	}
+}
+
+func TestRowSpans(t *testing.T) {
+	qt := treeWithCells([2]Dim{-1, 0}, [2]Dim{0, 0}, [2]Dim{1, 0}, [2]Dim{3, 0}, [2]Dim{-5, 2}, [2]Dim{-3, -3})
+	// a completely live 4x4 subtree touching the run at x = 3
+	for y := Dim(0); y < 4; y++ {
+		for x := Dim(4); x < 8; x++ {
+			qt = qt.SetCell(x, y, 1)
+		}
+	}
+	spans := qt.RowSpans(-1, 2)
+	assert.Equal(t, map[Dim][]Span{
+		0: {{-1, 1}, {3, 7}},
+		1: {{4, 7}},
+		2: {{-5, -5}, {4, 7}},
+	}, spans)
+
+	assert.Empty(t, qt.RowSpans(-2, -1))
+	assert.Equal(t, map[Dim][]Span{-3: {{-3, -3}}}, qt.RowSpans(-100, -2))
+}
+
+func TestFindLifeRuns(t *testing.T) {
+	qt := treeWithCells([2]Dim{-3, -2}, [2]Dim{-2, -2}, [2]Dim{0, -2}, [2]Dim{5, 1}, [2]Dim{-8, 1}, [2]Dim{4, 6})
+	var rows []Dim
+	runs := make(map[Dim][]Span)
+	qt.FindLifeRuns(func(y Dim, s Span) {
+		if len(rows) == 0 || rows[len(rows)-1] != y {
+			rows = append(rows, y)
+		}
+		runs[y] = append(runs[y], s)
+	})
+	assert.Equal(t, []Dim{-2, 1, 6}, rows)
+	assert.Equal(t, qt.RowSpans(-100, 100), runs)
+
+	// runs continue across node borders
+	qt = EmptyTree(4)
+	for x := Dim(-8); x < 8; x++ {
+		qt = qt.SetCell(x, 0, 1)
+	}
+	runs = make(map[Dim][]Span)
+	qt.FindLifeRuns(func(y Dim, s Span) { runs[y] = append(runs[y], s) })
+	assert.Equal(t, map[Dim][]Span{0: {{-8, 7}}}, runs)
+
+	EmptyTree(5).FindLifeRuns(func(y Dim, s Span) { t.Fail() })
+}
+
+func TestPopulationIn(t *testing.T) {
+	qt := treeWithCells([2]Dim{0, 0}, [2]Dim{1, 0}, [2]Dim{5, 5}, [2]Dim{-7, 3}).GrowToFit(1<<20, 0)
+	assert.Equal(t, Dim(4), qt.PopulationIn(-100, -100, 100, 100))
+	assert.Equal(t, Dim(2), qt.PopulationIn(0, 0, 1, 0))
+	assert.Equal(t, Dim(1), qt.PopulationIn(1, -1, 5, 4))
+	assert.Equal(t, Dim(0), qt.PopulationIn(10, 10, 1<<20, 1<<20))
+	assert.Equal(t, qt.Population, qt.PopulationIn(-(1<<40), -(1<<40), 1<<40, 1<<40))
+}
+
+func TestClearRegion(t *testing.T) {
+	qt := blinker().SetCell(3, 3, 1).SetCell(-4, -4, 1)
+	cleared := qt.ClearRegion(-1, -4, 3, 0)
+	assert.Equal(t, Dim(2), cleared.Population)
+	assert.Equal(t, Dim(1), cleared.Cell(3, 3))
+	assert.Equal(t, Dim(1), cleared.Cell(-4, -4))
+	assert.Equal(t, qt.ClearRegion(-1, 0, 1, 0), qt.SetCell(-1, 0, 0).SetCell(0, 0, 0).SetCell(1, 0, 0))
+
+	// an empty region returns qt itself
+	assert.True(t, qt == qt.ClearRegion(-3, -3, 2, -1))
+	assert.True(t, qt == qt.ClearRegion(100, 100, 200, 200))
+	assert.True(t, EmptyTree(3) == qt.ClearRegion(-1<<40, -1<<40, 1<<40, 1<<40))
+}
+
+func TestFillRegion(t *testing.T) {
+	qt := blinker()
+	filled := qt.FillRegion(-2, -3, 3, 1, 1)
+	assert.Equal(t, Dim(30), filled.Population)
+	assert.Equal(t, Dim(30), filled.PopulationIn(-2, -3, 3, 1))
+	assert.True(t, filled.NE.SW == conwaySpace.fullTree(1))
+	assert.True(t, filled == filled.FillRegion(-1, -2, 2, 0, 1))
+
+	// the whole tree
+	full := qt.FillRegion(-1<<40, -1<<40, 1<<40, 1<<40, 1)
+	assert.Equal(t, Dim(64), full.Population)
+	assert.True(t, full == conwaySpace.fullTree(3))
+
+	// 0 clears
+	assert.Equal(t, qt.ClearRegion(-1, 0, 0, 0), qt.FillRegion(-1, 0, 0, 0, 0))
+}
+
+func TestFillRegionLarge(t *testing.T) {
+	// 2^33 x 2^33 cells, more than fit into Population, inside a level 35 tree
+	qt := EmptyTree(35).SetCell(-1<<33, 0, 1)
+	filled := qt.FillRegion(-1<<32, -1<<32, 1<<32-1, 1<<32-1, 1)
+	assert.Equal(t, MaxPopulation, filled.Population)
+	assert.Equal(t, new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 66), big.NewInt(1)), filled.PopulationBig())
+	assert.Equal(t, Dim(1), filled.Cell(-1<<32, -1<<32))
+	assert.Equal(t, Dim(1), filled.Cell(1<<32-1, 1<<32-1))
+	assert.Equal(t, Dim(0), filled.Cell(1<<32, 0))
+	assert.Equal(t, Dim(0), filled.Cell(0, -1<<32-1))
+	assert.Equal(t, Dim(1), filled.Cell(-1<<33, 0))
+	// the filled quadrants are recognized as full, so filling them again keeps the tree
+	assert.True(t, filled.NW.SE.SE.isFull())
+	assert.True(t, filled == filled.FillRegion(-1<<31, -1<<31, 1<<31, 1<<31, 1))
+	cleared := filled.ClearRegion(-1<<32, -1<<32, 1<<32-1, 1<<32-1)
+	assert.Equal(t, Dim(1), cleared.Population)
+	assert.Equal(t, Dim(1), cleared.Cell(-1<<33, 0))
+}
+
+func TestIsFull(t *testing.T) {
+	assert.True(t, liveLeaf.isFull())
+	assert.False(t, deadLeaf.isFull())
+	assert.True(t, FullTree(31).isFull())
+	assert.True(t, FullTree(50).isFull())
+	assert.False(t, FullTree(50).SetCell(0, 0, 0).isFull())
+	// the population of an empty tree equals 2^level squared modulo 2^64
+	assert.False(t, EmptyTree(32).isFull())
+	assert.Equal(t, Dim(1), EmptyTree(32).FillRegion(0, 0, 0, 0, 1).Population)
+}
@@ -0,0 +1,174 @@
+package quadtree
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Rect is an axis aligned rectangle in cell coordinates. Min and max are inclusive.
+type Rect struct {
+	MinX, MinY, MaxX, MaxY Dim
+}
+
+// Empty reports whether r contains no cells
+func (r Rect) Empty() bool {
+	return r.MaxX < r.MinX || r.MaxY < r.MinY
+}
+
+// Viewport is a window of Width x Height cells with its north west corner at (OriginX, OriginY).
+// It can lie anywhere, cells outside of the rendered tree are dead.
+type Viewport struct {
+	OriginX, OriginY Dim
+	Width, Height    Dim
+}
+
+// Rect returns the cells covered by v
+func (v Viewport) Rect() Rect {
+	return Rect{v.OriginX, v.OriginY, v.OriginX + v.Width - 1, v.OriginY + v.Height - 1}
+}
+
+// RenderOptions controls how cells are drawn by WritePNGWith.
+// Zero values select a scale of 1, white dead cells and black live cells.
+type RenderOptions struct {
+	Scale int // side length in pixels of one cell
+
+	// AgeColors draws cells that are alive in the rendered tree but dead in Previous with BornColor,
+	// so a renderer handed two successive generations can tell newborn cells from persistent ones.
+	AgeColors bool
+	Previous  *Quadtree
+
+	DeadColor, LiveColor, BornColor color.Color
+}
+
+var (
+	defaultDeadColor color.Color = color.White
+	defaultLiveColor color.Color = color.Black
+	defaultBornColor color.Color = color.RGBA{0x00, 0xa0, 0x00, 0xff}
+)
+
+// palette returns the colors for dead, live and born cells in this order
+func (opts *RenderOptions) palette() color.Palette {
+	p := color.Palette{defaultDeadColor, defaultLiveColor, defaultBornColor}
+	for i, c := range []color.Color{opts.DeadColor, opts.LiveColor, opts.BornColor} {
+		if c != nil {
+			p[i] = c
+		}
+	}
+	return p
+}
+
+// cellOrDead returns the value of cell (x,y) or 0 if it lies outside of qt
+func (qt *Quadtree) cellOrDead(x, y Dim) Dim {
+	if !qt.contains(x, y) {
+		return 0
+	}
+	return qt.Cell(x, y)
+}
+
+// MaxRenderPixels is the largest number of pixels Render and WritePNGWith draw into one image.
+const MaxRenderPixels = 1 << 28
+
+// imageSize returns the width and height in pixels of window drawn with scale x scale blocks per cell.
+// It returns an error if the image would have more than MaxRenderPixels pixels.
+func imageSize(window Rect, scale int) (width, height int, err error) {
+	limit := Dim(MaxRenderPixels / scale)
+	// the window of a level 63 tree is 2^63 cells wide, so the sides are compared before adding 1
+	spanX, spanY := window.MaxX-window.MinX, window.MaxY-window.MinY
+	if spanX >= limit || spanY >= limit || (spanX+1)*(spanY+1) > limit/Dim(scale) {
+		return 0, 0, fmt.Errorf("can't render window %v with scale %v, the image would have more than %v pixels",
+			window, scale, MaxRenderPixels)
+	}
+	return int(spanX+1) * scale, int(spanY+1) * scale, nil
+}
+
+// Render returns a grayscale image of the cells from (minX,minY) to (maxX,maxY), inclusive, with white dead cells
+// and black live cells, each drawn as a scale x scale block. Only subtrees with live cells overlapping the window are
+// descended, so rendering a small window of a huge universe is cheap. The window is clamped to the coordinate range
+// of qt, a window outside of it gives an empty image. It returns an error if the image would be too large.
+func (qt *Quadtree) Render(minX, minY, maxX, maxY Dim, scale int) (*image.Gray, error) {
+	window := Rect{minX, minY, maxX, maxY}.intersect(qt.bounds())
+	if window.Empty() {
+		return image.NewGray(image.Rectangle{}), nil
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	width, height, err := imageSize(window, scale)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	qt.eachLiveIn(window, func(x, y Dim) {
+		px := int(x-window.MinX) * scale
+		py := int(y-window.MinY) * scale
+		for dy := 0; dy < scale; dy++ {
+			row := img.Pix[img.PixOffset(px, py+dy):]
+			for dx := 0; dx < scale; dx++ {
+				row[dx] = 0
+			}
+		}
+	})
+	return img, nil
+}
+
+// WritePNG encodes the cells within window as PNG image to w, with white dead cells and black live cells, each drawn
+// as a scale x scale block. The window is clamped to the coordinate range of qt, so the image never covers more than
+// the tree. It returns an error if nothing of the window is left or the image would be too large.
+func (qt *Quadtree) WritePNG(w io.Writer, window Rect, scale int) error {
+	return qt.WritePNGWith(w, window, RenderOptions{Scale: scale})
+}
+
+// WritePNGWith is like WritePNG, but draws the cells as controlled by opts.
+func (qt *Quadtree) WritePNGWith(w io.Writer, window Rect, opts RenderOptions) error {
+	clamped := window.intersect(qt.bounds())
+	if clamped.Empty() {
+		return fmt.Errorf("can't render window %v, it doesn't overlap the tree %v", window, qt.bounds())
+	}
+	window = clamped
+	scale := opts.Scale
+	if scale < 1 {
+		scale = 1
+	}
+	width, height, err := imageSize(window, scale)
+	if err != nil {
+		return err
+	}
+	img := image.NewPaletted(image.Rect(0, 0, width, height), opts.palette())
+
+	qt.eachLiveIn(window, func(x, y Dim) {
+		index := uint8(1)
+		if opts.AgeColors && opts.Previous != nil && opts.Previous.cellOrDead(x, y) == 0 {
+			index = 2
+		}
+		px := int(x-window.MinX) * scale
+		py := int(y-window.MinY) * scale
+		for dy := 0; dy < scale; dy++ {
+			for dx := 0; dx < scale; dx++ {
+				img.SetColorIndex(px+dx, py+dy, index)
+			}
+		}
+	})
+	return png.Encode(w, img)
+}
+
+// RenderDiff calls draw for every cell within window whose state differs between prev and qt, alive tells whether
+// it's alive in qt. Cells that change between two live states are drawn too.
+// Subtrees that prev and qt share are skipped without being descended, so for a mostly static universe
+// the cost follows the number of changes, not the size of the window. The window is clamped to the trees.
+func (qt *Quadtree) RenderDiff(prev *Quadtree, window Rect, draw func(x, y Dim, alive bool)) {
+	a, b := alignLevels(prev, qt)
+	window = window.intersect(a.bounds())
+	if window.Empty() {
+		return
+	}
+	origin := a.origin()
+	diffNodes(a, b, origin, origin, window, func(x, y Dim, from, to State) {
+		draw(x, y, to != 0)
+	})
+}
@@ -0,0 +1,154 @@
+package quadtree
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePNG(t *testing.T) {
+	qt := blinker()
+	var buf bytes.Buffer
+	err := qt.WritePNG(&buf, Rect{-2, -2, 2, 2}, 2)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, img.Bounds().Dx())
+	assert.Equal(t, 10, img.Bounds().Dy())
+	// (-1, 0) is at pixel (2, 4) and (3, 5)
+	assert.Equal(t, color.GrayModel.Convert(color.Black), color.GrayModel.Convert(img.At(2, 4)))
+	assert.Equal(t, color.GrayModel.Convert(color.Black), color.GrayModel.Convert(img.At(3, 5)))
+	assert.Equal(t, color.GrayModel.Convert(color.White), color.GrayModel.Convert(img.At(0, 0)))
+
+	assert.Error(t, qt.WritePNG(&buf, Rect{1, 1, 0, 0}, 1))
+	assert.Error(t, qt.WritePNG(&buf, Rect{-2, -2, 2, 2}, 1<<30))
+}
+
+func TestRender(t *testing.T) {
+	qt := blinker()
+	img, err := qt.Render(-2, -1, 2, 1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 15, img.Bounds().Dx())
+	assert.Equal(t, 9, img.Bounds().Dy())
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 15; x++ {
+			expect := uint8(0xff)
+			if y >= 3 && y < 6 && x >= 3 && x < 12 {
+				expect = 0
+			}
+			assert.Equal(t, expect, img.GrayAt(x, y).Y, "pixel (%v, %v)", x, y)
+		}
+	}
+
+	// windows are clamped to the tree, windows outside of it or empty windows give empty images
+	img, err = qt.Render(-1<<40, 0, 1<<40, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, img.Bounds().Dx())
+	assert.Equal(t, 1, img.Bounds().Dy())
+	assert.Equal(t, uint8(0), img.GrayAt(3, 0).Y)
+	img, err = qt.Render(1000, 1000, 1001, 1001, 1)
+	assert.NoError(t, err)
+	assert.True(t, img.Bounds().Empty())
+	img, err = qt.Render(1, 1, 0, 0, 1)
+	assert.NoError(t, err)
+	assert.True(t, img.Bounds().Empty())
+}
+
+func TestRenderTooLarge(t *testing.T) {
+	qt := EmptyTree(40).SetCell(0, 0, 1)
+	_, err := qt.Render(-1<<39, -1<<39, 1<<39-1, 1<<39-1, 1)
+	assert.Error(t, err)
+	_, err = qt.Render(0, 0, 1<<20, 0, 1<<20)
+	assert.Error(t, err)
+	_, err = blinker().Render(0, 0, 0, 0, 1<<62)
+	assert.Error(t, err)
+
+	// the full bounds of the biggest tree are 2^63 cells wide
+	huge := EmptyTree(MaxLevel).SetCell(0, 0, 1)
+	bounds := huge.bounds()
+	_, err = huge.Render(bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY, 1)
+	assert.Error(t, err)
+	var buf bytes.Buffer
+	assert.Error(t, huge.WritePNG(&buf, bounds, 1))
+	assert.Error(t, huge.WritePNG(&buf, Rect{bounds.MinX, 0, bounds.MaxX, 0}, 1))
+
+	img, err := qt.Render(0, 0, 1<<10-1, 1<<10-1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(0), img.GrayAt(0, 0).Y)
+}
+
+func TestWritePNGAgeColors(t *testing.T) {
+	prev := blinker()
+	qt := prev.NextGen()
+	born := color.RGBA{0xff, 0, 0, 0xff}
+	var buf bytes.Buffer
+	err := qt.WritePNGWith(&buf, Rect{-1, -1, 1, 1}, RenderOptions{AgeColors: true, Previous: prev, BornColor: born})
+	assert.NoError(t, err)
+
+	img, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	// the center survives, north and south are born
+	assert.Equal(t, color.RGBAModel.Convert(color.Black), color.RGBAModel.Convert(img.At(1, 1)))
+	assert.Equal(t, color.RGBAModel.Convert(born), color.RGBAModel.Convert(img.At(1, 0)))
+	assert.Equal(t, color.RGBAModel.Convert(born), color.RGBAModel.Convert(img.At(1, 2)))
+	assert.Equal(t, color.RGBAModel.Convert(color.White), color.RGBAModel.Convert(img.At(0, 1)))
+}
+
+func TestViewport(t *testing.T) {
+	assert.Equal(t, Rect{-2, 3, 7, 3}, Viewport{-2, 3, 10, 1}.Rect())
+	assert.True(t, Viewport{0, 0, 0, 5}.Rect().Empty())
+
+	// a viewport far outside of the tree is clamped away
+	var buf bytes.Buffer
+	assert.Error(t, blinker().WritePNG(&buf, Viewport{1000, 1000, 4, 4}.Rect(), 1))
+}
+
+func TestWritePNGClamped(t *testing.T) {
+	qt := blinker() // level 3, from -4 to 3
+	var buf bytes.Buffer
+	assert.NoError(t, qt.WritePNG(&buf, Rect{-1 << 40, -1, 1 << 40, 0}, 1))
+	img, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, img.Bounds().Dx())
+	assert.Equal(t, 2, img.Bounds().Dy())
+	assert.Equal(t, color.GrayModel.Convert(color.Black), color.GrayModel.Convert(img.At(3, 1)))
+	assert.Equal(t, color.GrayModel.Convert(color.White), color.GrayModel.Convert(img.At(3, 0)))
+}
+
+func TestRenderDiff(t *testing.T) {
+	prev := blinker().SetCell(-4, -4, 1)
+	qt := prev.NextGen().SetCell(3, 3, 1)
+	type change struct {
+		x, y  Dim
+		alive bool
+	}
+	var changes []change
+	qt.RenderDiff(prev, Rect{-1 << 40, -1 << 40, 1 << 40, 1 << 40}, func(x, y Dim, alive bool) {
+		changes = append(changes, change{x, y, alive})
+	})
+	assert.ElementsMatch(t, []change{{-4, -4, false}, {-1, 0, false}, {1, 0, false}, {0, -1, true}, {0, 1, true}, {3, 3, true}}, changes)
+
+	changes = nil
+	qt.RenderDiff(prev, Rect{0, 0, 3, 3}, func(x, y Dim, alive bool) {
+		changes = append(changes, change{x, y, alive})
+	})
+	assert.ElementsMatch(t, []change{{1, 0, false}, {0, 1, true}, {3, 3, true}}, changes)
+
+	qt.RenderDiff(qt, Rect{-4, -4, 3, 3}, func(x, y Dim, alive bool) {
+		t.Errorf("unchanged cell (%v, %v) drawn", x, y)
+	})
+	qt.RenderDiff(prev, Rect{100, 100, 200, 200}, func(x, y Dim, alive bool) {
+		t.Errorf("cell (%v, %v) outside of the window drawn", x, y)
+	})
+
+	// cells changing between live states are drawn
+	changes = nil
+	qt.SetCellState(3, 3, 2).RenderDiff(qt, Rect{-4, -4, 3, 3}, func(x, y Dim, alive bool) {
+		changes = append(changes, change{x, y, alive})
+	})
+	assert.Equal(t, []change{{3, 3, true}}, changes)
+}
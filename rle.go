@@ -0,0 +1,282 @@
+package quadtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// rleHeader is the parsed "x = m, y = n, rule = abc" line of a RLE file
+type rleHeader struct {
+	width, height Dim
+	rule          Rule
+}
+
+// parseRLEHeader parses the header line of a RLE file
+func parseRLEHeader(line string) (header rleHeader, err error) {
+	header.rule = Conway
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(line, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return header, fmt.Errorf("malformed header field %q", strings.TrimSpace(field))
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		seen[key] = true
+		switch key {
+		case "x", "y":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 {
+				return header, fmt.Errorf("invalid %v %q", key, value)
+			}
+			if key == "x" {
+				header.width = n
+			} else {
+				header.height = n
+			}
+		case "rule":
+			if header.rule, err = ParseRule(value); err != nil {
+				return header, err
+			}
+			if header.rule.Born[0] {
+				return header, fmt.Errorf("rule %v with birth on 0 neighbors isn't supported", header.rule)
+			}
+		default:
+			return header, fmt.Errorf("unknown header field %q", key)
+		}
+	}
+	if !seen["x"] || !seen["y"] {
+		return header, fmt.Errorf("header needs x and y")
+	}
+	return header, nil
+}
+
+// MaxRLECells is the maximum number of live cells LoadRLE accepts
+const MaxRLECells = 1 << 24
+
+// LoadRLE reads a pattern in run length encoded (.rle) format.
+// The pattern is placed with the center of its declared bounding box at the origin and evolves by the rule of the header.
+// The body has to stay within the width and height declared in the header and has to be terminated by '!',
+// otherwise a *ParseError pointing at the offending line is returned. So do run counts larger than both and
+// patterns with more than MaxRLECells live cells, which a short file could declare otherwise.
+func LoadRLE(r io.Reader) (*Quadtree, error) {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	var header *rleHeader
+	var maxCount Dim
+	var runs []rleRun
+	var population Dim
+	x, y := Dim(0), Dim(0)
+	count := Dim(0)
+	hasCount := false
+	done := false
+
+	for !done && scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if header == nil {
+			h, err := parseRLEHeader(line)
+			if err != nil {
+				return nil, parseErrorf(lineNumber, "%v", err)
+			}
+			header = &h
+			maxCount = h.width
+			if h.height > maxCount {
+				maxCount = h.height
+			}
+			continue
+		}
+
+		for _, c := range line {
+			if unicode.IsDigit(c) {
+				digit := Dim(c - '0')
+				if count > (maxCount-digit)/10 {
+					return nil, parseErrorf(lineNumber, "run count exceeds the declared size %vx%v", header.width, header.height)
+				}
+				count = count*10 + digit
+				hasCount = true
+				continue
+			}
+			if unicode.IsSpace(c) {
+				continue
+			}
+			if c == '!' {
+				if hasCount {
+					return nil, parseErrorf(lineNumber, "count without tag")
+				}
+				done = true
+				break
+			}
+			run := Dim(1)
+			if hasCount {
+				run = count
+			}
+			count, hasCount = 0, false
+			switch c {
+			case 'b':
+				x += run
+			case 'o':
+				if y >= header.height {
+					return nil, parseErrorf(lineNumber, "pattern has more rows than the declared height %v", header.height)
+				}
+				if population += run; population > MaxRLECells {
+					return nil, parseErrorf(lineNumber, "pattern has more than %v live cells", MaxRLECells)
+				}
+				runs = append(runs, rleRun{x, y, run})
+				x += run
+			case '$':
+				x = 0
+				y += run
+				if y > header.height {
+					return nil, parseErrorf(lineNumber, "pattern has more rows than the declared height %v", header.height)
+				}
+			default:
+				return nil, parseErrorf(lineNumber, "unexpected tag %q", c)
+			}
+			if x > header.width {
+				return nil, parseErrorf(lineNumber, "row %v is longer than the declared width %v", y+1, header.width)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, parseErrorf(lineNumber, "missing header")
+	}
+	if hasCount {
+		return nil, parseErrorf(lineNumber, "count without tag")
+	}
+	if !done {
+		return nil, parseErrorf(lineNumber, "missing terminating '!'")
+	}
+
+	offsetX, offsetY := -header.width/2, -header.height/2
+	qt := spaceFor(header.rule).emptyTree(1)
+	qt = qt.GrowToFit(offsetX, offsetY)
+	qt = qt.GrowToFit(header.width-1+offsetX, header.height-1+offsetY)
+	for _, run := range runs {
+		qt = qt.FillRegion(run.x+offsetX, run.y+offsetY, run.x+run.n-1+offsetX, run.y+offsetY, 1)
+	}
+	return qt, nil
+}
+
+// rleRun is a run of n live cells starting at (x,y)
+type rleRun struct {
+	x, y, n Dim
+}
+
+// encodeRLEBody returns the run length encoded body of the pattern with live cells at points, terminated by '!'.
+// The pattern starts at the minimal coordinates of points.
+func encodeRLEBody(points []point) string {
+	if len(points) == 0 {
+		return "!"
+	}
+	sorted := append([]point(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].y != sorted[j].y {
+			return sorted[i].y < sorted[j].y
+		}
+		return sorted[i].x < sorted[j].x
+	})
+	minX := sorted[0].x
+	for _, p := range sorted {
+		if p.x < minX {
+			minX = p.x
+		}
+	}
+
+	var b strings.Builder
+	writeRun := func(count Dim, tag byte) {
+		if count > 1 {
+			b.WriteString(strconv.FormatInt(count, 10))
+		}
+		b.WriteByte(tag)
+	}
+	y, x := sorted[0].y, minX
+	for i := 0; i < len(sorted); {
+		p := sorted[i]
+		if p.y != y {
+			writeRun(p.y-y, '$')
+			y, x = p.y, minX
+		}
+		if p.x > x {
+			writeRun(p.x-x, 'b')
+		}
+		run := Dim(1)
+		for i+int(run) < len(sorted) && sorted[i+int(run)] == (point{p.x + run, p.y}) {
+			run++
+		}
+		writeRun(run, 'o')
+		x = p.x + run
+		i += int(run)
+	}
+	b.WriteByte('!')
+	return b.String()
+}
+
+// rleLineLength is the maximum length of the body lines written by ExportRLE
+const rleLineLength = 70
+
+// rleEncoder writes runs of a RLE body, wrapping lines before they exceed rleLineLength
+type rleEncoder struct {
+	w       *bufio.Writer
+	column  int
+	scratch []byte
+}
+
+// run writes count times tag, the count is left out if it's 1
+func (e *rleEncoder) run(count Dim, tag byte) {
+	e.scratch = e.scratch[:0]
+	if count > 1 {
+		e.scratch = strconv.AppendInt(e.scratch, count, 10)
+	}
+	e.scratch = append(e.scratch, tag)
+	if e.column+len(e.scratch) > rleLineLength {
+		e.w.WriteByte('\n')
+		e.column = 0
+	}
+	e.w.Write(e.scratch)
+	e.column += len(e.scratch)
+}
+
+// ExportRLE writes the live cells of qt in run length encoded (.rle) format. See ExportRLEWith.
+func (qt *Quadtree) ExportRLE(w io.Writer) error {
+	return qt.ExportRLEWith(w, WriteOptions{})
+}
+
+// ExportRLEWith writes the live cells of qt in run length encoded (.rle) format with the header describing their
+// bounding box and the rule of qt. The body is streamed row by row from FindLifeRuns, so even huge patterns are written with bounded memory.
+func (qt *Quadtree) ExportRLEWith(w io.Writer, opts WriteOptions) error {
+	bw := bufio.NewWriter(opts.writer(w))
+	box, ok := qt.boundingBox()
+	width, height := Dim(0), Dim(0)
+	if ok {
+		width, height = box.MaxX-box.MinX+1, box.MaxY-box.MinY+1
+	}
+	fmt.Fprintf(bw, "x = %d, y = %d, rule = %v\n", width, height, qt.Rule())
+
+	e := &rleEncoder{w: bw}
+	x, y := box.MinX, box.MinY
+	qt.FindLifeRuns(func(row Dim, s Span) {
+		if row != y {
+			e.run(row-y, '$')
+			x, y = box.MinX, row
+		}
+		if s.Start > x {
+			e.run(s.Start-x, 'b')
+		}
+		e.run(s.End-s.Start+1, 'o')
+		x = s.End + 1
+	})
+	e.run(1, '!')
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
@@ -0,0 +1,173 @@
+package quadtree
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const rleGlider = `#N Glider
+#C the smallest spaceship
+x = 3, y = 3, rule = B3/S23
+bob$2bo$3o!
+`
+
+func TestLoadRLE(t *testing.T) {
+	qt, err := LoadRLE(strings.NewReader(rleGlider))
+	assert.NoError(t, err)
+	assert.Equal(t, Dim(5), qt.Population)
+	// top left corner of the 3x3 box is at (-1,-1)
+	assert.Equal(t, Dim(1), qt.Cell(0, -1))
+	assert.Equal(t, Dim(1), qt.Cell(1, 0))
+	assert.Equal(t, Dim(1), qt.Cell(-1, 1))
+	assert.Equal(t, Dim(1), qt.Cell(0, 1))
+	assert.Equal(t, Dim(1), qt.Cell(1, 1))
+}
+
+func TestLoadRLEMultiLine(t *testing.T) {
+	// runs split over several lines, empty rows and trailing text after '!'
+	qt, err := LoadRLE(strings.NewReader("x = 10, y = 4\n10o$\n2$\n2b\n3o!\ncomment"))
+	assert.NoError(t, err)
+	assert.Equal(t, Dim(13), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(-5, -2))
+	assert.Equal(t, Dim(1), qt.Cell(4, -2))
+	assert.Equal(t, Dim(0), qt.Cell(-5, -1))
+	assert.Equal(t, Dim(1), qt.Cell(-3, 1))
+	assert.Equal(t, Dim(1), qt.Cell(-1, 1))
+
+	qt, err = LoadRLE(strings.NewReader("x = 0, y = 0\n!"))
+	assert.NoError(t, err)
+	assert.True(t, qt.IsEmpty())
+}
+
+func TestLoadRLERule(t *testing.T) {
+	qt, err := LoadRLE(strings.NewReader("x = 3, y = 3, rule = B36/S23\nbo$2bo$3o!\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "B36/S23", qt.Rule().String())
+
+	var b strings.Builder
+	assert.NoError(t, qt.ExportRLE(&b))
+	assert.Equal(t, "x = 3, y = 3, rule = B36/S23\nbo$2bo$3o!\n", b.String())
+}
+
+func TestLoadRLEErrors(t *testing.T) {
+	for _, c := range []struct {
+		input string
+		line  int
+	}{
+		{"", 0},
+		{"#C only a comment\n", 1},
+		{"x = 3\nbob!", 1},
+		{"x = 3, y = a\nbob!", 1},
+		{"x = 3, y = 3, size = 4\nbob!", 1},
+		{"x 3, y = 3\nbob!", 1},
+		{"x = 3, y = 3, rule = B9/S23\nbob!", 1},
+		{"x = 3, y = 3, rule = B03/S23\nbob!", 1},
+		{"x = 3, y = 3\nbob$2bo$3o", 2},
+		{"x = 3, y = 3\nbob$2bo$3o3!", 2},
+		{"x = 3, y = 3\nbob$2bo$3o$\n3", 3},
+		{"x = 3, y = 3\nbob$2bo$3x!", 2},
+		// body disagrees with the declared size
+		{"x = 3, y = 3\nbob$2bo$4o!", 2},
+		{"x = 3, y = 3\nbob$\n2bo$3o$o!", 3},
+		{"x = 3, y = 2\nbob$2bo$3o!", 2},
+		{"x = 3, y = 3\n2$\n3$o!", 3},
+		// counts beyond the declared size, also those that overflow
+		{"x = 3, y = 3\n4b!", 2},
+		{"x = 3, y = 3\n\n99999999999999999999999o!", 3},
+		{"x = 9223372036854775807, y = 1\n92233720368547758070o!", 2},
+		// too many cells for a few bytes
+		{"x = 10000000, y = 100\n10000000o$\n10000000o!", 3},
+	} {
+		_, err := LoadRLE(strings.NewReader(c.input))
+		if assert.Error(t, err, c.input) {
+			assert.IsType(t, &ParseError{}, err, c.input)
+			assert.Equal(t, c.line, err.(*ParseError).Line, c.input)
+		}
+	}
+}
+
+func TestLoadRLEHugeRun(t *testing.T) {
+	// runs are filled without a point per cell
+	qt, err := LoadRLE(strings.NewReader("x = 1000000, y = 2\n1000000o$bo!"))
+	assert.NoError(t, err)
+	assert.Equal(t, Dim(1000001), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(-500000, -1))
+	assert.Equal(t, Dim(1), qt.Cell(499999, -1))
+	assert.Equal(t, Dim(1), qt.Cell(-499999, 0))
+	assert.Equal(t, Dim(0), qt.Cell(-500000, 0))
+}
+
+func TestParseRLEHeader(t *testing.T) {
+	header, err := parseRLEHeader("x = 3, y = 2, rule = 23/36")
+	assert.NoError(t, err)
+	highLife, _ := ParseRule("B36/S23")
+	assert.Equal(t, rleHeader{3, 2, highLife}, header)
+
+	header, err = parseRLEHeader("x=1,y=4")
+	assert.NoError(t, err)
+	assert.Equal(t, rleHeader{1, 4, Conway}, header)
+}
+
+func TestEncodeRLEBody(t *testing.T) {
+	qt, err := LoadRLE(strings.NewReader(rleGlider))
+	assert.NoError(t, err)
+	assert.Equal(t, "bo$2bo$3o!", encodeRLEBody(qt.livePoints()))
+
+	assert.Equal(t, "2o3$b3o!", encodeRLEBody([]point{{5, 5}, {6, 5}, {6, 8}, {7, 8}, {8, 8}}))
+	assert.Equal(t, "!", encodeRLEBody(nil))
+}
+
+func TestExportRLE(t *testing.T) {
+	qt, err := LoadRLE(strings.NewReader(rleGlider))
+	assert.NoError(t, err)
+	var b strings.Builder
+	assert.NoError(t, qt.ExportRLE(&b))
+	assert.Equal(t, "x = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, qt.ExportRLEWith(&b, WriteOptions{LineEnding: "\r\n"}))
+	assert.Equal(t, "x = 3, y = 3, rule = B3/S23\r\nbo$2bo$3o!\r\n", b.String())
+
+	// the output only depends on the pattern, not on its position or the size of the tree
+	var moved strings.Builder
+	assert.NoError(t, glider().GrowToFit(100, 100).ExportRLE(&moved))
+	assert.Equal(t, "x = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n", moved.String())
+
+	b.Reset()
+	assert.NoError(t, EmptyTree(3).ExportRLE(&b))
+	assert.Equal(t, "x = 0, y = 0, rule = B3/S23\n!\n", b.String())
+
+	// long rows are wrapped and read back unchanged
+	qt = EmptyTree(7)
+	for x := Dim(-64); x < 64; x += 2 {
+		qt = qt.SetCell(x, -10, 1).SetCell(x+1, 20, 1)
+	}
+	b.Reset()
+	assert.NoError(t, qt.ExportRLE(&b))
+	for _, line := range strings.Split(b.String(), "\n") {
+		assert.True(t, len(line) <= rleLineLength, line)
+	}
+	read, err := LoadRLE(strings.NewReader(b.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, encodeRLEBody(qt.livePoints()), encodeRLEBody(read.livePoints()))
+}
+
+func TestExportRLEBoundedMemory(t *testing.T) {
+	// every other cell of every other row in a 2048x2048 square, a million cells sharing a handful of nodes
+	qt := NewTree(Childs{DeadLeaf(), DeadLeaf(), LiveLeaf(), DeadLeaf()})
+	for qt.Level < 11 {
+		qt = NewTree(Childs{qt, qt, qt, qt})
+	}
+	assert.Equal(t, Dim(1<<20), qt.Population)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	assert.NoError(t, qt.ExportRLE(io.Discard))
+	runtime.ReadMemStats(&after)
+	// collecting the cells alone would take 16 bytes per cell
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < 1<<20, "allocated %v bytes", after.TotalAlloc-before.TotalAlloc)
+}
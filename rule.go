@@ -0,0 +1,177 @@
+package quadtree
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Rule is an outer totalistic Life-like rule. A dead cell with n live neighbors is born if Born[n],
+// a live cell with n live neighbors survives if Survive[n].
+type Rule struct {
+	Born    [9]bool
+	Survive [9]bool
+}
+
+// Conway is the rule of Conway's Game of Life, B3/S23
+var Conway = Rule{
+	Born:    [9]bool{3: true},
+	Survive: [9]bool{2: true, 3: true},
+}
+
+// ruleSpace holds the leaves of one rule. Trees of different rules are built from different leaves,
+// so they never share nodes and the cached next generations of one rule can't leak into another.
+type ruleSpace struct {
+	rule       Rule
+	live, dead *Quadtree
+	leaves     [MaxState + 1]*Quadtree // leaf of every state, dead and live among them
+}
+
+var (
+	conwaySpace = newRuleSpace(Conway)
+	spaces      = map[Rule]*ruleSpace{Conway: conwaySpace}
+	spacesMutex sync.Mutex
+)
+
+// newRuleSpace returns a ruleSpace with its own leaves
+func newRuleSpace(rule Rule) *ruleSpace {
+	s := &ruleSpace{rule: rule}
+	s.dead = &Quadtree{Population: 0, space: s}
+	s.leaves[0] = s.dead
+	for state := 1; state <= MaxState; state++ {
+		s.leaves[state] = &Quadtree{Population: 1, space: s, state: State(state)}
+	}
+	s.live = s.leaves[1]
+	return s
+}
+
+// spaceFor returns the ruleSpace of rule, the same rule always gets the same space
+func spaceFor(rule Rule) *ruleSpace {
+	spacesMutex.Lock()
+	defer spacesMutex.Unlock()
+	s, ok := spaces[rule]
+	if !ok {
+		s = newRuleSpace(rule)
+		spaces[rule] = s
+	}
+	return s
+}
+
+// Rule returns the rule qt evolves by
+func (qt *Quadtree) Rule() Rule {
+	return qt.space.rule
+}
+
+// WithRule returns a tree with the live cells of qt that evolves by rule. Trees of different rules don't share
+// any nodes, so mixing them in NewTree panics. Rules where cells are born without live neighbors (B0) aren't supported.
+func (qt *Quadtree) WithRule(rule Rule) *Quadtree {
+	if rule.Born[0] {
+		panic(fmt.Sprintf("rule %v lets empty space come alive, which hashlife can't simulate", rule))
+	}
+	s := spaceFor(rule)
+	if s == qt.space {
+		return qt
+	}
+	return qt.inSpace(s, make(map[*Quadtree]*Quadtree))
+}
+
+// inSpace rebuilds qt from the leaves of s
+func (qt *Quadtree) inSpace(s *ruleSpace, memo map[*Quadtree]*Quadtree) *Quadtree {
+	if qt.IsLeaf() {
+		return s.leaves[qt.state]
+	}
+	if rebuilt, ok := memo[qt]; ok {
+		return rebuilt
+	}
+	rebuilt := NewTree(Childs{
+		SE: qt.SE.inSpace(s, memo),
+		SW: qt.SW.inSpace(s, memo),
+		NW: qt.NW.inSpace(s, memo),
+		NE: qt.NE.inSpace(s, memo),
+	})
+	memo[qt] = rebuilt
+	return rebuilt
+}
+
+// ParseRule parses a rule in B/S notation like "B36/S23" or in the legacy S/B notation like "23/36"
+func ParseRule(s string) (Rule, error) {
+	var rule Rule
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return rule, fmt.Errorf("rule %q doesn't consist of two parts separated by /", s)
+	}
+
+	legacy := true
+	for _, part := range parts {
+		if part != "" && (part[0] == 'B' || part[0] == 'b' || part[0] == 'S' || part[0] == 's') {
+			legacy = false
+		}
+	}
+	for i, part := range parts {
+		counts := &rule.Survive
+		if legacy {
+			if i == 1 {
+				counts = &rule.Born
+			}
+		} else {
+			if part == "" {
+				return rule, fmt.Errorf("rule %q has an empty part", s)
+			}
+			switch part[0] {
+			case 'B', 'b':
+				counts = &rule.Born
+			case 'S', 's':
+			default:
+				return rule, fmt.Errorf("rule %q mixes B/S and S/B notation", s)
+			}
+			part = part[1:]
+		}
+		for _, c := range part {
+			if c < '0' || c > '8' {
+				return rule, fmt.Errorf("invalid neighbor count %q in rule %q", c, s)
+			}
+			counts[c-'0'] = true
+		}
+	}
+	return rule, nil
+}
+
+// String returns the rule in B/S notation
+func (r Rule) String() string {
+	var b strings.Builder
+	b.WriteString("B")
+	for n, born := range r.Born {
+		if born {
+			fmt.Fprint(&b, n)
+		}
+	}
+	b.WriteString("/S")
+	for n, survive := range r.Survive {
+		if survive {
+			fmt.Fprint(&b, n)
+		}
+	}
+	return b.String()
+}
+
+// nextState reports whether a cell with the given state and number of live neighbors is alive in the next generation
+func (r Rule) nextState(alive bool, neighbors int) bool {
+	if alive {
+		return r.Survive[neighbors]
+	}
+	return r.Born[neighbors]
+}
+
+// CompareRules runs qt for gens generations under rule a and under rule b with NextGen. It returns both results and
+// their difference as Diff does: added are the cells alive only under rule b, removed those alive only under rule a.
+// The universe grows as needed, so no cells are lost at the border. Both runs use their own nodes,
+// so they don't affect each other's caches.
+func CompareRules(qt *Quadtree, a, b Rule, gens int) (resultA, resultB *Quadtree, added, removed []struct{ X, Y Dim }) {
+	resultA, resultB = qt.WithRule(a), qt.WithRule(b)
+	for i := 0; i < gens; i++ {
+		resultA = resultA.expand().NextGen()
+		resultB = resultB.expand().NextGen()
+	}
+	added, removed = resultA.Diff(resultB.WithRule(a))
+	return resultA, resultB, added, removed
+}
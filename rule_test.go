@@ -0,0 +1,107 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, s := range []string{"B3/S23", "b3/s23", "S23/B3", "23/3", " B3/S23 "} {
+		rule, err := ParseRule(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, Conway, rule, s)
+	}
+
+	highLife, err := ParseRule("B36/S23")
+	assert.NoError(t, err)
+	assert.Equal(t, "B36/S23", highLife.String())
+	legacyHighLife, err := ParseRule("23/36")
+	assert.NoError(t, err)
+	assert.Equal(t, highLife, legacyHighLife)
+
+	// seeds has no survival
+	seeds, err := ParseRule("B2/S")
+	assert.NoError(t, err)
+	assert.Equal(t, Rule{Born: [9]bool{2: true}}, seeds)
+	assert.Equal(t, "B2/S", seeds.String())
+
+	for _, s := range []string{"", "B3", "B3/S23/C", "B3/23", "B9/S23", "Bx/S23", "X3/S23"} {
+		_, err := ParseRule(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	assert.Equal(t, "B3/S23", Conway.String())
+	dayAndNight, err := ParseRule(Rule{Born: [9]bool{3: true, 6: true, 7: true, 8: true}, Survive: [9]bool{3: true, 4: true, 6: true, 7: true, 8: true}}.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "B3678/S34678", dayAndNight.String())
+}
+
+func TestCompareRules(t *testing.T) {
+	highLife, _ := ParseRule("B36/S23")
+
+	// the center has six live neighbors, so only HighLife gives birth to it
+	qt := treeWithCells([2]Dim{-1, -1}, [2]Dim{0, -1}, [2]Dim{1, -1}, [2]Dim{-1, 1}, [2]Dim{0, 1}, [2]Dim{1, 1})
+	conway, high, added, removed := CompareRules(qt, Conway, highLife, 1)
+	assert.Equal(t, Dim(0), conway.Cell(0, 0))
+	assert.Equal(t, Dim(1), high.Cell(0, 0))
+	assert.Equal(t, []struct{ X, Y Dim }{{0, 0}}, added)
+	assert.Empty(t, removed)
+
+	conway, high, added, removed = CompareRules(qt, Conway, highLife, 2)
+	assert.NotEmpty(t, append(added, removed...))
+	for _, c := range added {
+		assert.Equal(t, Dim(0), conway.Cell(c.X, c.Y))
+		assert.Equal(t, Dim(1), high.Cell(c.X, c.Y))
+	}
+	for _, c := range removed {
+		assert.Equal(t, Dim(1), conway.Cell(c.X, c.Y))
+		assert.Equal(t, Dim(0), high.Cell(c.X, c.Y))
+	}
+
+	// both runs agree with NextGen under the same rule
+	a, b, added, removed := CompareRules(glider(), Conway, Conway, 12)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+	assert.True(t, a == b)
+	expect := glider()
+	for i := 0; i < 12; i++ {
+		expect = expect.expand().NextGen()
+	}
+	assert.Equal(t, expect.livePoints(), a.livePoints())
+}
+
+func TestWithRule(t *testing.T) {
+	highLife, _ := ParseRule("B36/S23")
+	dayAndNight, _ := ParseRule("B3678/S34678")
+	assert.Equal(t, Conway, blinker().Rule())
+
+	// the engine matches the brute force reference under every rule
+	for _, rule := range []Rule{Conway, highLife, dayAndNight} {
+		qt := EmptyTree(5).SetCells(randomCells(300, 24)).WithRule(rule)
+		assert.Equal(t, rule, qt.Rule())
+		for gen := 0; gen < 8; gen++ {
+			assert.Equal(t, BruteForceStep(qt), qt.NextGen(), "%v generation %v", rule, gen)
+			qt = qt.NextGen()
+		}
+	}
+
+	// trees of different rules share no nodes, so their cached next generations don't mix
+	qt := treeWithCells([2]Dim{-1, -1}, [2]Dim{0, -1}, [2]Dim{1, -1}, [2]Dim{-1, 1}, [2]Dim{0, 1}, [2]Dim{1, 1}).GrowToFit(8, 8)
+	high := qt.WithRule(highLife)
+	assert.Equal(t, 0, SharedNodeCount(qt, high))
+	assert.Equal(t, Dim(0), qt.NextGen().Cell(0, 0))
+	assert.Equal(t, Dim(1), high.NextGen().Cell(0, 0))
+	assert.Equal(t, Dim(0), qt.NextGen().Cell(0, 0))
+	assert.True(t, qt == high.WithRule(Conway))
+	assert.True(t, high == high.WithRule(highLife))
+
+	// steps keep the rule
+	assert.Equal(t, highLife, high.NextGen().NextGenerationStep(2).Rule())
+
+	assert.Panics(t, func() { NewTree(Childs{high.SE, qt.SW, qt.NW, qt.NE}) })
+	seeds, _ := ParseRule("B0/S")
+	assert.Panics(t, func() { qt.WithRule(seeds) })
+}
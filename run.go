@@ -0,0 +1,24 @@
+package quadtree
+
+import (
+	"fmt"
+	"time"
+)
+
+// RunFor advances qt until d has elapsed and returns the final tree and the number of generations computed.
+// The clock is read every checkEvery generations, so the budget may be exceeded by up to checkEvery steps.
+// The universe grows as needed, so no cells are lost at the border.
+func (qt *Quadtree) RunFor(d time.Duration, checkEvery int) (*Quadtree, int) {
+	if checkEvery <= 0 {
+		panic(fmt.Sprintf("checkEvery has to be positive, got %v", checkEvery))
+	}
+	deadline := time.Now().Add(d)
+	gens := 0
+	for time.Now().Before(deadline) {
+		for i := 0; i < checkEvery; i++ {
+			qt = qt.expand().NextGen()
+		}
+		gens += checkEvery
+	}
+	return qt, gens
+}
@@ -0,0 +1,24 @@
+package quadtree
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFor(t *testing.T) {
+	qt, gens := blinker().RunFor(0, 10)
+	assert.Equal(t, 0, gens)
+	assert.Equal(t, blinker(), qt)
+
+	qt, gens = glider().RunFor(20*time.Millisecond, 4)
+	assert.True(t, gens > 0)
+	assert.Equal(t, 0, gens%4)
+	box, _ := qt.boundingBox()
+	start, _ := glider().boundingBox()
+	shift := Dim(gens / 4)
+	assert.Equal(t, Rect{start.MinX + shift, start.MinY + shift, start.MaxX + shift, start.MaxY + shift}, box)
+
+	assert.Panics(t, func() { blinker().RunFor(time.Second, 0) })
+}
@@ -0,0 +1,104 @@
+package quadtree
+
+// stepScope is a temporary cache on top of the node cache used by StepForwardScoped. It belongs to the
+// goroutine stepping, so it needs no lock and trees built by other goroutines meanwhile never end up in it.
+type stepScope struct {
+	nodes NodeMap                 // nodes built while stepping that the node cache doesn't know
+	next  map[*Quadtree]*Quadtree // next generations computed while stepping
+}
+
+// StepForwardScoped advances qt by k generations like k calls of NextGen, but keeps all intermediate nodes in a
+// temporary cache that is discarded afterwards. Only the nodes of the result are added to the global cache,
+// so a single deep jump doesn't permanently bloat shared memory.
+func (qt *Quadtree) StepForwardScoped(k uint) *Quadtree {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	s := &stepScope{make(NodeMap), make(map[*Quadtree]*Quadtree)}
+	for i := uint(0); i < k; i++ {
+		qt = s.nextGeneration(qt.growWith(s.newTree))
+	}
+
+	qt = qt.intern(make(map[*Quadtree]*Quadtree))
+	publishCounters()
+	return qt
+}
+
+// newTree is NewTree that reads from the node cache and s, but only inserts into s
+func (s *stepScope) newTree(childs Childs) *Quadtree {
+	qt, ok := cacheGet(childs)
+	if !ok {
+		qt, ok = s.nodes[childs]
+	}
+	if ok {
+		cacheHit.Add(1)
+		qt.touch()
+		return qt
+	}
+	cacheMiss.Add(1)
+	qt = newNode(childs)
+	if qt.cacheable() {
+		s.nodes[childs] = qt
+	}
+	return qt
+}
+
+// nextGeneration is NextGeneration building all nodes with s.newTree. The next generations are only memoized
+// in s, so no node of the node cache refers to a node of s afterwards.
+func (s *stepScope) nextGeneration(qt *Quadtree) *Quadtree {
+	if next := qt.next.Load(); next != nil {
+		return next
+	}
+	if next, ok := s.next[qt]; ok {
+		return next
+	}
+	var next *Quadtree
+	if qt.Level == 2 {
+		next = qt.slowSimulationWith(s.newTree)
+	} else {
+		q := qt.quadrantNodesWith(s.newTree)
+		next = s.newTree(Childs{
+			NW: s.nextGeneration(q[NorthWest]),
+			NE: s.nextGeneration(q[NorthEast]),
+			SW: s.nextGeneration(q[SouthWest]),
+			SE: s.nextGeneration(q[SouthEast]),
+		})
+	}
+	s.next[qt] = next
+	return next
+}
+
+// intern rebuilds qt through NewTree, so that it consists of globally cached nodes
+func (qt *Quadtree) intern(memo map[*Quadtree]*Quadtree) *Quadtree {
+	if qt.IsLeaf() {
+		return qt
+	}
+	if interned, ok := memo[qt]; ok {
+		return interned
+	}
+	interned := NewTree(Childs{qt.SE.intern(memo), qt.SW.intern(memo), qt.NW.intern(memo), qt.NE.intern(memo)})
+	memo[qt] = interned
+	return interned
+}
+
+// Detach returns a structurally identical copy of qt built from private nodes that the cache doesn't know.
+// Nodes shared within qt stay shared in the copy, so it costs one node per unique node of qt.
+// A cached tree stays readable after its nodes were evicted, but its nodes keep the cached next generations
+// alive and may be handed out to other trees. The detached copy holds no next generations and is never
+// affected by what happens to the cache. Stepping it builds cached nodes again, which it then shares.
+func (qt *Quadtree) Detach() *Quadtree {
+	return qt.detach(make(map[*Quadtree]*Quadtree))
+}
+
+func (qt *Quadtree) detach(memo map[*Quadtree]*Quadtree) *Quadtree {
+	if qt.IsLeaf() {
+		return qt
+	}
+	if detached, ok := memo[qt]; ok {
+		return detached
+	}
+	childs := Childs{SE: qt.SE.detach(memo), SW: qt.SW.detach(memo), NW: qt.NW.detach(memo), NE: qt.NE.detach(memo)}
+	detached := &Quadtree{Level: qt.Level, Childs: childs, Population: qt.Population, space: qt.space}
+	memo[qt] = detached
+	return detached
+}
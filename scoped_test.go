@@ -0,0 +1,66 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepForwardScoped(t *testing.T) {
+	qt := soupSquare(16, 7).grow().grow()
+	before := len(nodeMap)
+	scoped := qt.StepForwardScoped(30)
+
+	// only the nodes of the result may have been added
+	nodes := make(map[*Quadtree]struct{})
+	scoped.collectNodes(nodes)
+	assert.True(t, len(nodeMap) <= before+len(nodes))
+
+	expect := qt
+	for i := 0; i < 30; i++ {
+		expect = expect.NextGen()
+	}
+	assert.True(t, expect == scoped)
+	assert.Equal(t, qt, qt.StepForwardScoped(0))
+}
+
+func TestStepForwardScopedConcurrent(t *testing.T) {
+	qt := soupSquare(16, 11).grow().grow()
+	done := make(chan *Quadtree)
+	go func() {
+		done <- qt.StepForwardScoped(8)
+	}()
+	// trees built meanwhile go to the node cache, not to the scope
+	var built []*Quadtree
+	for i := Dim(0); i < 200; i++ {
+		built = append(built, EmptyTree(6).SetCells([]struct{ X, Y, V Dim }{{i % 32, i / 32, 1}, {-i % 32, 3, 1}}))
+	}
+	scoped := <-done
+	for _, tree := range built {
+		assert.True(t, tree.isCached())
+	}
+
+	expect := qt
+	for i := 0; i < 8; i++ {
+		expect = expect.NextGen()
+	}
+	assert.True(t, expect == scoped)
+}
+
+func TestDetach(t *testing.T) {
+	old := nodeMap
+	defer SetNodeCache(old)
+	qt := glider().GrowToFit(40, 40)
+	qt.NextGen()
+	detached := qt.Detach()
+	assert.False(t, detached == qt)
+	assert.Nil(t, detached.next.Load())
+	_, ok := LookupNode(detached.NW.Childs)
+	assert.False(t, ok)
+	// shared nodes stay shared
+	assert.True(t, detached.NE.NE == detached.NW.NW)
+
+	SetNodeCache(nil)
+	assert.Equal(t, qt.livePoints(), detached.livePoints())
+	assert.True(t, equalNodes(BruteForceStep(qt), detached.NextGen()))
+}
@@ -0,0 +1,147 @@
+package quadtree
+
+// alignLevels grows the smaller of a and b until both have the same level
+func alignLevels(a, b *Quadtree) (*Quadtree, *Quadtree) {
+	for a.Level < b.Level {
+		a = a.grow()
+	}
+	for b.Level < a.Level {
+		b = b.grow()
+	}
+	return a, b
+}
+
+// Xor returns a tree with live cells where exactly one of a and b is alive. Both have to belong to the same rule.
+// The result has the level of the bigger input.
+func Xor(a, b *Quadtree) *Quadtree {
+	a, b = alignLevels(a, b)
+	return xor(a, b)
+}
+
+func xor(a, b *Quadtree) *Quadtree {
+	if a == b {
+		return a.space.emptyTree(a.Level)
+	}
+	if a.IsEmpty() {
+		return b
+	}
+	if b.IsEmpty() {
+		return a
+	}
+	if a.IsLeaf() {
+		return a.space.dead // both alive
+	}
+	return NewTree(Childs{xor(a.SE, b.SE), xor(a.SW, b.SW), xor(a.NW, b.NW), xor(a.NE, b.NE)})
+}
+
+// changes returns a tree with live cells where the states of a and b differ. Both have the same level and rule.
+func changes(a, b *Quadtree) *Quadtree {
+	if a == b {
+		return a.space.emptyTree(a.Level)
+	}
+	if a.IsLeaf() {
+		return a.space.live
+	}
+	if a.IsEmpty() {
+		return b
+	}
+	if b.IsEmpty() {
+		return a
+	}
+	return NewTree(Childs{changes(a.SE, b.SE), changes(a.SW, b.SW), changes(a.NW, b.NW), changes(a.NE, b.NE)})
+}
+
+// Diff returns the cells that are alive in other but dead in qt (born) and those alive in qt but dead in other (died).
+// Cells that stay alive with a different State are in neither list.
+// Both trees are centered at the origin, the smaller one is grown to the level of the bigger one.
+// Subtrees that both trees share, or that are empty in both, are skipped without being descended.
+func (qt *Quadtree) Diff(other *Quadtree) (born, died []struct{ X, Y Dim }) {
+	a, b := alignLevels(qt, other)
+	origin := a.origin()
+	diffNodes(a, b, origin, origin, a.bounds(), func(x, y Dim, from, to State) {
+		if from == 0 {
+			born = append(born, struct{ X, Y Dim }{x, y})
+		} else if to == 0 {
+			died = append(died, struct{ X, Y Dim }{x, y})
+		}
+	})
+	return born, died
+}
+
+// diffNodes calls fn for every cell within r whose state differs between the nodes a and b of the same level, whose
+// north west corner is at (x,y). from is the state of the cell in a, to its state in b.
+func diffNodes(a, b *Quadtree, x, y Dim, r Rect, fn func(x, y Dim, from, to State)) {
+	if a == b || a.IsEmpty() && b.IsEmpty() {
+		return
+	}
+	if disjoint, _ := a.overlap(x, y, r); disjoint {
+		return
+	}
+	if a.IsLeaf() {
+		if a.state != b.state {
+			fn(x, y, a.state, b.state)
+		}
+		return
+	}
+	half := Dim(1) << (a.Level - 1)
+	diffNodes(a.NW, b.NW, x, y, r, fn)
+	diffNodes(a.NE, b.NE, x+half, y, r, fn)
+	diffNodes(a.SW, b.SW, x, y+half, r, fn)
+	diffNodes(a.SE, b.SE, x+half, y+half, r, fn)
+}
+
+// Overlay returns qt with the live cells of other, moved by (dx,dy), added to it. The tree grows so nothing is clipped.
+// Both trees are merged node by node, nodes are only rebuilt where both have live cells. Shifts that Translate
+// can't do by restructuring stamp the cells of other one by one.
+func (qt *Quadtree) Overlay(other *Quadtree, dx, dy Dim) *Quadtree {
+	a, b := alignLevels(qt, other.Translate(dx, dy))
+	return or(a, b)
+}
+
+func or(a, b *Quadtree) *Quadtree {
+	if a == b || b.IsEmpty() {
+		return a
+	}
+	if a.IsEmpty() {
+		return b
+	}
+	if a.IsLeaf() {
+		return a // both alive
+	}
+	return NewTree(Childs{or(a.SE, b.SE), or(a.SW, b.SW), or(a.NW, b.NW), or(a.NE, b.NE)})
+}
+
+// EqualPattern reports whether qt and other have the same live cells in the same states up to translation, regardless
+// of their levels and the dead space around the cells. Both trees are moved so the north west corner of their bounding
+// box is at the origin and then compared node by node.
+func (qt *Quadtree) EqualPattern(other *Quadtree) bool {
+	boxA, okA := qt.boundingBox()
+	boxB, okB := other.boundingBox()
+	if !okA || !okB {
+		return okA == okB
+	}
+	if qt.Population != other.Population ||
+		boxA.MaxX-boxA.MinX != boxB.MaxX-boxB.MinX || boxA.MaxY-boxA.MinY != boxB.MaxY-boxB.MinY {
+		return false
+	}
+	a, b := alignLevels(qt.Translate(-boxA.MinX, -boxA.MinY).Shrink(), other.Translate(-boxB.MinX, -boxB.MinY).Shrink())
+	return equalNodes(a, b)
+}
+
+// equalNodes reports whether a and b of the same level have the same cell states, even if they belong to different rules.
+// Identical and empty nodes aren't descended.
+func equalNodes(a, b *Quadtree) bool {
+	if a == b {
+		return true
+	}
+	if a.IsLeaf() {
+		return a.state == b.state
+	}
+	if a.Population != b.Population {
+		return false
+	}
+	if a.IsEmpty() {
+		return true
+	}
+	return equalNodes(a.NW, b.NW) && equalNodes(a.NE, b.NE) && equalNodes(a.SW, b.SW) && equalNodes(a.SE, b.SE)
+}
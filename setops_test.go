@@ -0,0 +1,98 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXor(t *testing.T) {
+	a := blinker()
+	b := a.NextGen()
+	x := Xor(a, b)
+	assert.Equal(t, Dim(4), x.Population)
+	assert.Equal(t, Dim(0), x.Cell(0, 0))
+	assert.Equal(t, Dim(1), x.Cell(-1, 0))
+	assert.Equal(t, Dim(1), x.Cell(1, 0))
+	assert.Equal(t, Dim(1), x.Cell(0, -1))
+	assert.Equal(t, Dim(1), x.Cell(0, 1))
+
+	// xor is its own inverse
+	assert.Equal(t, b, Xor(a, x))
+	assert.Equal(t, EmptyTree(3), Xor(a, a))
+
+	// different levels
+	x = Xor(a.GrowToFit(20, 20), treeWithCells([2]Dim{0, 0}))
+	assert.Equal(t, uint(6), x.Level)
+	assert.Equal(t, Dim(2), x.Population)
+	assert.Equal(t, Dim(0), x.Cell(0, 0))
+}
+
+func TestOverlay(t *testing.T) {
+	// a fleet of gliders placed away from the origin
+	qt := blinker()
+	for _, offset := range [][2]Dim{{16, 16}, {-40, 8}, {5, -3}} {
+		qt = qt.Overlay(glider(), offset[0], offset[1])
+	}
+	assert.Equal(t, Dim(3+3*5), qt.Population)
+	for _, p := range glider().livePoints() {
+		assert.Equal(t, Dim(1), qt.Cell(p.x+16, p.y+16))
+		assert.Equal(t, Dim(1), qt.Cell(p.x-40, p.y+8))
+		assert.Equal(t, Dim(1), qt.Cell(p.x+5, p.y-3))
+	}
+	assert.Equal(t, Dim(1), qt.Cell(-1, 0))
+
+	// overlapping cells are counted once
+	assert.True(t, blinker() == blinker().Overlay(blinker(), 0, 0))
+	assert.Equal(t, Dim(4), blinker().Overlay(blinker(), 1, 0).Population)
+}
+
+func TestEqualPattern(t *testing.T) {
+	qt := glider()
+	assert.True(t, qt.EqualPattern(qt))
+	assert.True(t, qt.EqualPattern(qt.GrowToFit(1000, 1000)))
+	assert.True(t, qt.EqualPattern(qt.Translate(-37, 12)))
+	assert.True(t, qt.EqualPattern(qt.Translate(1<<20, 1<<21)))
+	highLife, _ := ParseRule("B36/S23")
+	assert.True(t, qt.EqualPattern(qt.WithRule(highLife)))
+
+	// a glider is back in shape after four generations
+	moved := qt
+	for i := 0; i < 4; i++ {
+		moved = moved.expand().NextGen()
+	}
+	assert.True(t, qt.EqualPattern(moved))
+	assert.False(t, qt.EqualPattern(moved.expand().NextGen()))
+	assert.False(t, qt.EqualPattern(qt.FlipX()))
+	assert.False(t, qt.EqualPattern(blinker()))
+
+	assert.True(t, EmptyTree(3).EqualPattern(EmptyTree(10)))
+	assert.False(t, EmptyTree(3).EqualPattern(qt))
+
+	// cell states have to match too
+	multi := blinker().SetCellState(1, 0, 2)
+	assert.False(t, blinker().EqualPattern(multi))
+	assert.True(t, multi.EqualPattern(multi.Translate(5, -3)))
+	assert.False(t, multi.EqualPattern(blinker().SetCellState(1, 0, 3)))
+}
+
+func TestDiff(t *testing.T) {
+	prev := blinker()
+	born, died := prev.Diff(prev.NextGen())
+	assert.Equal(t, []struct{ X, Y Dim }{{0, -1}, {0, 1}}, born)
+	assert.Equal(t, []struct{ X, Y Dim }{{-1, 0}, {1, 0}}, died)
+
+	born, died = prev.Diff(prev)
+	assert.Empty(t, born)
+	assert.Empty(t, died)
+
+	// trees of different levels are aligned at the origin
+	born, died = prev.Diff(EmptyTree(6).SetCell(-1, 0, 1).SetCell(20, -30, 1))
+	assert.Equal(t, []struct{ X, Y Dim }{{20, -30}}, born)
+	assert.Equal(t, []struct{ X, Y Dim }{{0, 0}, {1, 0}}, died)
+
+	// a change between live states is neither born nor died
+	born, died = prev.Diff(prev.SetCellState(0, 0, 2).SetCell(1, 0, 0))
+	assert.Empty(t, born)
+	assert.Equal(t, []struct{ X, Y Dim }{{1, 0}}, died)
+}
@@ -0,0 +1,66 @@
+package quadtree
+
+import "math/rand"
+
+// RandomSoup returns a tree of the given level under Conway's rule where each cell is alive with probability density.
+// The cells are drawn from a random source seeded with seed, so the same arguments always give the same soup.
+// Every cell is sampled, so it's only suitable for moderate levels.
+func RandomSoup(level uint, density float64, seed int64) *Quadtree {
+	return conwaySpace.randomTree(level, density, rand.New(rand.NewSource(seed)))
+}
+
+// randomTree builds a tree of the given level under the rule of s with the cells drawn from r, north west first
+func (s *ruleSpace) randomTree(level uint, density float64, r *rand.Rand) *Quadtree {
+	if level == 0 {
+		if r.Float64() < density {
+			return s.live
+		}
+		return s.dead
+	}
+	nw := s.randomTree(level-1, density, r)
+	ne := s.randomTree(level-1, density, r)
+	sw := s.randomTree(level-1, density, r)
+	se := s.randomTree(level-1, density, r)
+	return NewTree(Childs{NW: nw, NE: ne, SW: sw, SE: se})
+}
+
+// soupSquare returns a size x size square of random cells centered at the origin, drawn like RandomSoup with
+// density 1/2. The smallest random tree holding the square is built and the cells around the square are cleared.
+func soupSquare(size int, seed int64) *Quadtree {
+	level := uint(1)
+	for Dim(1)<<level < Dim(size) {
+		level++
+	}
+	qt := conwaySpace.randomTree(level, 0.5, rand.New(rand.NewSource(seed)))
+	b := qt.bounds()
+	start, end := -Dim(size)/2, -Dim(size)/2+Dim(size)-1
+	return qt.ClearRegion(b.MinX, b.MinY, b.MaxX, start-1).
+		ClearRegion(b.MinX, end+1, b.MaxX, b.MaxY).
+		ClearRegion(b.MinX, b.MinY, start-1, b.MaxY).
+		ClearRegion(end+1, b.MinY, b.MaxX, b.MaxY)
+}
+
+// SoupSearch runs a reproducible soup: a random size x size pattern generated from seed like RandomSoup with
+// density 1/2 is stepped until it reaches a state it has been in before, for at most maxGen generations.
+// It returns the final population, the generation at which the repeating cycle started and the final tree (the ash).
+// stableGen is -1 if the soup didn't stabilize within maxGen generations.
+// The universe grows as needed like in FindCycle, so no cells are lost, but a soup that emits a glider or another
+// spaceship never repeats.
+func SoupSearch(size int, seed int64, maxGen int) (finalPop Dim, stableGen int, ash *Quadtree) {
+	qt := soupSquare(size, seed).Shrink()
+
+	type state struct {
+		level  uint
+		childs Childs
+	}
+	// the childs of a shrunk tree are cached nodes and identify the state even if the tree itself isn't cached
+	seen := map[state]int{{qt.Level, qt.Childs}: 0}
+	for gen := 1; gen <= maxGen; gen++ {
+		qt = qt.expand().NextGen().Shrink()
+		if first, ok := seen[state{qt.Level, qt.Childs}]; ok {
+			return qt.Population, first, qt
+		}
+		seen[state{qt.Level, qt.Childs}] = gen
+	}
+	return qt.Population, -1, qt
+}
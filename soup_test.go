@@ -0,0 +1,68 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoupSquare(t *testing.T) {
+	a := soupSquare(8, 42)
+	assert.True(t, a == soupSquare(8, 42))
+	assert.False(t, a == soupSquare(8, 43))
+	assert.True(t, a == RandomSoup(3, 0.5, 42))
+	assert.True(t, a.Population > 0)
+
+	// cells outside the square are dead
+	b := soupSquare(5, 42)
+	assert.Equal(t, uint(3), b.Level)
+	assert.Equal(t, b.Population, b.PopulationIn(-2, -2, 2, 2))
+	assert.Equal(t, a.PopulationIn(-2, -2, 2, 2), b.Population)
+
+	assert.True(t, EmptyTree(1) == soupSquare(0, 1))
+}
+
+func TestRandomSoupDensity(t *testing.T) {
+	a := RandomSoup(6, 0.3, 7)
+	assert.True(t, a == RandomSoup(6, 0.3, 7))
+	assert.False(t, a == RandomSoup(6, 0.3, 8))
+	assert.Equal(t, uint(6), a.Level)
+	// 4096 cells with a standard deviation of about 29 live ones
+	assert.InDelta(t, 1229, a.Population, 100)
+
+	assert.True(t, EmptyTree(5) == RandomSoup(5, 0, 1))
+	assert.True(t, FullTree(5) == RandomSoup(5, 1, 1))
+}
+
+func TestSoupSearch(t *testing.T) {
+	pop, stableGen, ash := SoupSearch(8, 1, 2000)
+	assert.True(t, stableGen >= 0)
+	assert.Equal(t, ash.Population, pop)
+
+	// reproducible
+	pop2, stableGen2, ash2 := SoupSearch(8, 1, 2000)
+	assert.Equal(t, pop, pop2)
+	assert.Equal(t, stableGen, stableGen2)
+	assert.Equal(t, ash, ash2)
+
+	// the ash is the soup stepped without a border
+	for seed := int64(1); seed <= 5; seed++ {
+		_, stableGen, ash := SoupSearch(8, seed, 2000)
+		if stableGen < 0 {
+			continue
+		}
+		qt := soupSquare(8, seed)
+		for i := 0; i < stableGen; i++ {
+			qt = qt.Advance()
+		}
+		assert.True(t, qt.Shrink() == ash.Shrink(), "seed %v", seed)
+		preamble, _, states, ok := soupSquare(8, seed).FindCycle(2000)
+		assert.True(t, ok)
+		assert.Equal(t, stableGen, preamble, "seed %v", seed)
+		assert.True(t, states[0].Childs == ash.Childs, "seed %v", seed)
+	}
+
+	// not enough generations to stabilize
+	_, stableGen, _ = SoupSearch(8, 1, 0)
+	assert.Equal(t, -1, stableGen)
+}
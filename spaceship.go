@@ -0,0 +1,92 @@
+package quadtree
+
+import "fmt"
+
+// ProjectPosition returns the displacement of a spaceship that moves by (dx,dy) every period generations
+// after gens generations. Only completed periods are taken into account.
+func ProjectPosition(dx, dy Dim, period int, gens int) (offsetX, offsetY Dim) {
+	if period <= 0 {
+		panic(fmt.Sprintf("period has to be positive, got %v", period))
+	}
+	periods := Dim(gens / period)
+	return dx * periods, dy * periods
+}
+
+// StepRecenter advances qt by one generation and moves the live cells so that the center of their bounding box
+// is at the origin. The returned tree is as small as possible, (dx,dy) is the offset the pattern was moved back by.
+// Summing up (dx,dy) over all steps tracks the world position of a moving object while keeping the tree small.
+func (qt *Quadtree) StepRecenter() (next *Quadtree, dx, dy Dim) {
+	next = qt.grow().NextGen()
+	points := next.livePoints()
+	if len(points) == 0 {
+		return qt.space.emptyTree(1), 0, 0
+	}
+	box, _ := next.boundingBox()
+	dx = box.MinX + (box.MaxX-box.MinX+1)/2
+	dy = box.MinY + (box.MaxY-box.MinY+1)/2
+	return centeredTree(points, qt.space), dx, dy
+}
+
+// escapeMargin is the number of empty columns or rows that has to separate a glider from the rest of the pattern
+// before StripEscapingGliders considers it escaped.
+const escapeMargin = 4
+
+// gliderVelocity reports whether the cells at points form a glider under the rule of s and returns its displacement per period
+func gliderVelocity(points []point, s *ruleSpace) (dx, dy Dim, ok bool) {
+	if len(points) != 5 {
+		return 0, 0, false
+	}
+	start := centeredTree(points, s)
+	qt := start
+	for gen := 0; gen < 4; gen++ {
+		qt = qt.expand().NextGen()
+	}
+	startPoints, endPoints := start.livePoints(), qt.livePoints()
+	if len(endPoints) != 5 {
+		return 0, 0, false
+	}
+	dx, dy = endPoints[0].x-startPoints[0].x, endPoints[0].y-startPoints[0].y
+	if dx != 1 && dx != -1 || dy != 1 && dy != -1 {
+		return 0, 0, false
+	}
+	for i, p := range startPoints {
+		if endPoints[i] != (point{p.x + dx, p.y + dy}) {
+			return 0, 0, false
+		}
+	}
+	return dx, dy, true
+}
+
+// StripEscapingGliders returns qt without the gliders that move away from the rest of the pattern.
+// A glider has escaped once it lies beyond the bounding box of all other live cells in the direction it travels,
+// so it can't interact with them again. It should be called once the core of a reaction has stabilized,
+// a growing core can still catch up with a glider. A lone glider is returned unchanged.
+func (qt *Quadtree) StripEscapingGliders() *Quadtree {
+	groups := qt.componentPoints(1)
+	for stripped := true; stripped; {
+		stripped = false
+		for i, group := range groups {
+			dx, dy, ok := gliderVelocity(group, qt.space)
+			if !ok || len(groups) == 1 {
+				continue
+			}
+			var rest []point
+			for j, other := range groups {
+				if j != i {
+					rest = append(rest, other...)
+				}
+			}
+			g, r := pointBounds(group), pointBounds(rest)
+			if dx > 0 && g.MinX-r.MaxX > escapeMargin || dx < 0 && r.MinX-g.MaxX > escapeMargin ||
+				dy > 0 && g.MinY-r.MaxY > escapeMargin || dy < 0 && r.MinY-g.MaxY > escapeMargin {
+				for _, p := range group {
+					qt = qt.SetCell(p.x, p.y, 0)
+				}
+				groups = append(groups[:i], groups[i+1:]...)
+				stripped = true
+				break
+			}
+		}
+	}
+	return qt
+}
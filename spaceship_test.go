@@ -0,0 +1,88 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectPosition(t *testing.T) {
+	// glider: (1,1) every 4 generations
+	x, y := ProjectPosition(1, 1, 4, 40)
+	assert.Equal(t, Dim(10), x)
+	assert.Equal(t, Dim(10), y)
+
+	// incomplete periods are ignored
+	x, y = ProjectPosition(1, 1, 4, 43)
+	assert.Equal(t, Dim(10), x)
+	assert.Equal(t, Dim(10), y)
+
+	// lightweight spaceship: (-2,0) every 4 generations
+	x, y = ProjectPosition(-2, 0, 4, 8)
+	assert.Equal(t, Dim(-4), x)
+	assert.Equal(t, Dim(0), y)
+
+	assert.Panics(t, func() { ProjectPosition(1, 1, 0, 4) })
+}
+
+// glider returns a glider moving south east
+func glider() *Quadtree {
+	return Glider()
+}
+
+func TestStepRecenter(t *testing.T) {
+	qt := glider()
+	start := qt
+	x, y := Dim(0), Dim(0)
+	for gen := 1; gen <= 40; gen++ {
+		var dx, dy Dim
+		qt, dx, dy = qt.StepRecenter()
+		x, y = x+dx, y+dy
+		assert.Equal(t, Dim(5), qt.Population)
+		assert.True(t, qt.Level <= 3)
+		if gen%4 == 0 {
+			assert.Equal(t, Dim(gen/4), x)
+			assert.Equal(t, Dim(gen/4), y)
+			assert.Equal(t, start.GrowToFit(-4, -4), qt.GrowToFit(-4, -4))
+		}
+	}
+
+	qt, dx, dy := EmptyTree(4).StepRecenter()
+	assert.True(t, qt.IsEmpty())
+	assert.Equal(t, Dim(0), dx)
+	assert.Equal(t, Dim(0), dy)
+}
+
+// shifted returns the live cells of qt moved by (dx,dy)
+func shifted(qt *Quadtree, dx, dy Dim) [][2]Dim {
+	var cells [][2]Dim
+	for _, p := range qt.livePoints() {
+		cells = append(cells, [2]Dim{p.x + dx, p.y + dy})
+	}
+	return cells
+}
+
+func TestStripEscapingGliders(t *testing.T) {
+	block := [][2]Dim{{-20, -20}, {-19, -20}, {-20, -19}, {-19, -19}}
+
+	// a glider moving south east away from the block
+	cells := append(append([][2]Dim{}, block...), shifted(glider(), 0, 0)...)
+	stripped := treeWithCells(cells...).StripEscapingGliders()
+	assert.Equal(t, Dim(4), stripped.Population)
+	assert.Equal(t, treeWithCells(block...).livePoints(), stripped.livePoints())
+
+	// a glider moving towards the block stays
+	cells = append(append([][2]Dim{}, block...), shifted(glider(), -40, -40)...)
+	assert.Equal(t, Dim(9), treeWithCells(cells...).StripEscapingGliders().Population)
+
+	// two gliders behind each other both escape
+	cells = append(append(append([][2]Dim{}, block...), shifted(glider(), 0, 0)...), shifted(glider(), 10, 10)...)
+	assert.Equal(t, Dim(4), treeWithCells(cells...).StripEscapingGliders().Population)
+
+	// only gliders are removed
+	blinkerCells := shifted(blinker(), 10, 10)
+	cells = append(append([][2]Dim{}, block...), blinkerCells...)
+	assert.Equal(t, Dim(7), treeWithCells(cells...).StripEscapingGliders().Population)
+
+	assert.Equal(t, Dim(5), glider().StripEscapingGliders().Population)
+}
@@ -0,0 +1,10 @@
+package quadtree
+
+// State is the state of a cell. 0 is dead and 1 alive, multistate automata like Generations or Brian's Brain
+// use the higher states for dying cells. Every state but 0 counts towards the Population.
+//
+// The Life-like rules of NextGen treat every state but 0 as alive, the cells of the next generation have state 0 or 1.
+type State uint8
+
+// MaxState is the highest state of a cell
+const MaxState = 255
@@ -0,0 +1,33 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCellState(t *testing.T) {
+	qt := EmptyTree(3).SetCellState(0, 0, 2).SetCellState(1, 0, 1).SetCell(-1, 0, 7)
+	assert.Equal(t, State(2), qt.CellState(0, 0))
+	assert.Equal(t, Dim(2), qt.Cell(0, 0))
+	assert.Equal(t, State(1), qt.CellState(-1, 0))
+	assert.Equal(t, State(0), qt.CellState(2, 0))
+	assert.Equal(t, Dim(3), qt.Population)
+
+	// the same states give the same nodes, different ones don't
+	assert.True(t, qt == EmptyTree(3).SetCell(-1, 0, 1).SetCell(1, 0, 1).SetCellState(0, 0, 2))
+	other := qt.SetCellState(0, 0, 3)
+	assert.False(t, qt == other)
+	assert.NotEqual(t, qt.CanonicalHash(), other.CanonicalHash())
+	assert.Equal(t, Dim(3), other.Population)
+	assert.True(t, EmptyTree(3) == qt.SetCellState(0, 0, 0).SetCell(1, 0, 0).SetCell(-1, 0, 0))
+
+	// Life-like rules treat every state as alive
+	assert.True(t, blinker() == qt.GrowToFit(10, 10).NextGen().NextGen().Shrink())
+
+	highLife, _ := ParseRule("B36/S23")
+	assert.Equal(t, State(2), qt.WithRule(highLife).CellState(0, 0))
+
+	_, err := qt.GobEncode()
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package quadtree
+
+import "fmt"
+
+// Torus is a finite universe of Width x Height cells whose edges wrap around: a cell leaving at the east border
+// enters at the west border, one leaving at the south border enters at the north border.
+// Coordinates of any value are taken modulo the dimensions. Like quadtrees, a Torus is immutable.
+// Hashlife assumes an infinite plane, so Step counts the neighbors of the live cells one by one instead.
+type Torus struct {
+	Width, Height Dim
+	qt            *Quadtree // the cells from (0,0) to (Width-1,Height-1)
+}
+
+// NewTorus returns an empty Torus of width x height cells that evolves by rule
+func NewTorus(width, height Dim, rule Rule) Torus {
+	if width <= 0 || height <= 0 {
+		panic(fmt.Sprintf("a torus needs a positive size, got %v x %v", width, height))
+	}
+	qt := EmptyTree(1).WithRule(rule).GrowToFitRect(0, 0, width-1, height-1)
+	return Torus{width, height, qt}
+}
+
+// wrap returns the coordinates of (x,y) within the dimensions of t
+func (t Torus) wrap(x, y Dim) (Dim, Dim) {
+	return (x%t.Width + t.Width) % t.Width, (y%t.Height + t.Height) % t.Height
+}
+
+// Cell returns the value of the cell at (x,y), wrapped around the edges
+func (t Torus) Cell(x, y Dim) Dim {
+	x, y = t.wrap(x, y)
+	return t.qt.Cell(x, y)
+}
+
+// SetCell returns t with the cell at (x,y), wrapped around the edges, set to value
+func (t Torus) SetCell(x, y Dim, value Dim) Torus {
+	x, y = t.wrap(x, y)
+	t.qt = t.qt.SetCell(x, y, value)
+	return t
+}
+
+// Tree returns the cells of t as a quadtree, with the cell (0,0) of t at the origin
+func (t Torus) Tree() *Quadtree {
+	return t.qt
+}
+
+// Population returns the number of live cells of t
+func (t Torus) Population() Dim {
+	return t.qt.Population
+}
+
+// Step returns t advanced by one generation under its rule. Only live cells and their neighbors are visited,
+// so the cost follows the population, not the area.
+func (t Torus) Step() Torus {
+	neighbors := make(map[point]int)
+	alive := make(map[point]bool, t.qt.Population)
+	t.qt.LiveCells(func(x, y Dim) {
+		alive[point{x, y}] = true
+		for dy := Dim(-1); dy <= 1; dy++ {
+			for dx := Dim(-1); dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					nx, ny := t.wrap(x+dx, y+dy)
+					neighbors[point{nx, ny}]++
+				}
+			}
+		}
+	})
+	var cells []struct{ X, Y, V Dim }
+	rule := t.qt.Rule()
+	for p := range alive {
+		if rule.nextState(true, neighbors[p]) {
+			cells = append(cells, struct{ X, Y, V Dim }{p.x, p.y, 1})
+		}
+	}
+	for p, n := range neighbors {
+		if !alive[p] && rule.nextState(false, n) {
+			cells = append(cells, struct{ X, Y, V Dim }{p.x, p.y, 1})
+		}
+	}
+	t.qt = t.qt.space.emptyTree(t.qt.Level).SetCells(cells)
+	return t
+}
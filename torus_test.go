@@ -0,0 +1,48 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTorusWrap(t *testing.T) {
+	torus := NewTorus(5, 3, Conway).SetCell(-1, 4, 1)
+	assert.Equal(t, Dim(1), torus.Cell(4, 1))
+	assert.Equal(t, Dim(1), torus.Cell(9, -2))
+	assert.Equal(t, Dim(1), torus.Tree().Cell(4, 1))
+	assert.Equal(t, Dim(1), torus.Population())
+
+	assert.Panics(t, func() { NewTorus(0, 3, Conway) })
+}
+
+func TestTorusStep(t *testing.T) {
+	// a blinker across the west and east border
+	torus := NewTorus(6, 6, Conway).SetCell(-1, 2, 1).SetCell(0, 2, 1).SetCell(1, 2, 1)
+	next := torus.Step()
+	assert.Equal(t, Dim(3), next.Population())
+	assert.Equal(t, Dim(1), next.Cell(0, 1))
+	assert.Equal(t, Dim(1), next.Cell(0, 2))
+	assert.Equal(t, Dim(1), next.Cell(0, 3))
+	assert.Equal(t, torus.Tree(), next.Step().Tree())
+
+	// a glider crosses the whole torus in 4 generations per cell
+	torus = NewTorus(8, 8, Conway)
+	glider().LiveCells(func(x, y Dim) { torus = torus.SetCell(x, y, 1) })
+	start := torus
+	for gen := 0; gen < 4*8; gen++ {
+		torus = torus.Step()
+		assert.Equal(t, Dim(5), torus.Population(), "generation %v", gen+1)
+	}
+	assert.True(t, start.Tree() == torus.Tree())
+
+	// the rule of the torus is used: (1,1) has six neighbors
+	highLife, _ := ParseRule("B36/S23")
+	for rule, expect := range map[Rule]Dim{Conway: 0, highLife: 1} {
+		torus = NewTorus(5, 5, rule)
+		for _, c := range [][2]Dim{{0, 0}, {1, 0}, {2, 0}, {0, 2}, {1, 2}, {2, 2}} {
+			torus = torus.SetCell(c[0], c[1], 1)
+		}
+		assert.Equal(t, expect, torus.Step().Cell(1, 1), "rule %v", rule)
+	}
+}
@@ -0,0 +1,84 @@
+package quadtree
+
+// rearrange returns qt with the childs of every node reordered by arrange. The result is built through NewTree,
+// shared subtrees are rearranged only once.
+func (qt *Quadtree) rearrange(arrange func(c Childs) Childs, memo map[*Quadtree]*Quadtree) *Quadtree {
+	if qt.IsLeaf() || qt.IsEmpty() {
+		return qt
+	}
+	if rearranged, ok := memo[qt]; ok {
+		return rearranged
+	}
+	c := arrange(qt.Childs)
+	rearranged := NewTree(Childs{
+		SE: c.SE.rearrange(arrange, memo),
+		SW: c.SW.rearrange(arrange, memo),
+		NW: c.NW.rearrange(arrange, memo),
+		NE: c.NE.rearrange(arrange, memo),
+	})
+	memo[qt] = rearranged
+	return rearranged
+}
+
+// Rotate90 returns qt rotated clockwise around the center of the tree, the cell (x,y) moves to (-1-y,x).
+// Rotating four times returns qt itself as long as it's cached.
+func (qt *Quadtree) Rotate90() *Quadtree {
+	return qt.rearrange(func(c Childs) Childs {
+		return Childs{NE: c.NW, SE: c.NE, SW: c.SE, NW: c.SW}
+	}, make(map[*Quadtree]*Quadtree))
+}
+
+// FlipX returns qt mirrored across the vertical axis through the center of the tree, the cell (x,y) moves to (-1-x,y).
+func (qt *Quadtree) FlipX() *Quadtree {
+	return qt.rearrange(func(c Childs) Childs {
+		return Childs{NW: c.NE, NE: c.NW, SW: c.SE, SE: c.SW}
+	}, make(map[*Quadtree]*Quadtree))
+}
+
+// FlipY returns qt mirrored across the horizontal axis through the center of the tree, the cell (x,y) moves to (x,-1-y).
+func (qt *Quadtree) FlipY() *Quadtree {
+	return qt.rearrange(func(c Childs) Childs {
+		return Childs{NW: c.SW, SW: c.NW, NE: c.SE, SE: c.NE}
+	}, make(map[*Quadtree]*Quadtree))
+}
+
+// alignedShift is the smallest power of two shift Translate performs by restructuring nodes
+const alignedShift = 8
+
+// Translate returns qt with all live cells moved by (dx,dy), the tree grows as needed.
+// Shifts by multiples of alignedShift in both directions reassemble the result from the nodes of qt,
+// any other shift sets the moved cells in a new tree.
+func (qt *Quadtree) Translate(dx, dy Dim) *Quadtree {
+	box, ok := qt.boundingBox()
+	if !ok || dx == 0 && dy == 0 {
+		return qt
+	}
+	if dx%alignedShift != 0 || dy%alignedShift != 0 {
+		points := qt.livePoints()
+		cells := make([]struct{ X, Y, V Dim }, len(points))
+		for i, p := range points {
+			cells[i].X, cells[i].Y, cells[i].V = p.x+dx, p.y+dy, 1
+		}
+		moved := qt.space.emptyTree(qt.Level).SetCells(cells)
+		// SetCells only sets state 1, the moved tree already contains all cells so no growing is needed
+		for _, p := range points {
+			if state := qt.CellState(p.x, p.y); state != 1 {
+				moved = moved.SetCellState(p.x+dx, p.y+dy, state)
+			}
+		}
+		return moved
+	}
+
+	// the result is the window of the grown source whose cells end up in a tree of the target level
+	level := qt.Level
+	for !levelContains(level, box.MinX+dx, box.MinY+dy) || !levelContains(level, box.MaxX+dx, box.MaxY+dy) {
+		level++
+	}
+	size := Dim(1) << level
+	windowX, windowY := -size/2-dx, -size/2-dy
+	source := qt
+	for !source.contains(windowX, windowY) || !source.contains(windowX+size-1, windowY+size-1) {
+		source = source.grow()
+	}
+	return source.subnode(level, windowX-source.origin(), windowY-source.origin())
+}
@@ -0,0 +1,65 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotate90(t *testing.T) {
+	qt := glider().GrowToFit(20, 20)
+	rotated := qt.Rotate90()
+	assert.Equal(t, qt.Level, rotated.Level)
+	assert.Equal(t, qt.Population, rotated.Population)
+	for _, p := range qt.livePoints() {
+		assert.Equal(t, Dim(1), rotated.Cell(-1-p.y, p.x))
+	}
+	assert.True(t, qt == rotated.Rotate90().Rotate90().Rotate90())
+
+	// the glider now moves south west
+	moved := rotated
+	for i := 0; i < 4; i++ {
+		moved = moved.NextGen()
+	}
+	for _, p := range rotated.livePoints() {
+		assert.Equal(t, Dim(1), moved.Cell(p.x-1, p.y+1))
+	}
+
+	assert.True(t, EmptyTree(30) == EmptyTree(30).Rotate90())
+}
+
+func TestFlip(t *testing.T) {
+	qt := glider().GrowToFit(20, 20)
+	flippedX, flippedY := qt.FlipX(), qt.FlipY()
+	for _, p := range qt.livePoints() {
+		assert.Equal(t, Dim(1), flippedX.Cell(-1-p.x, p.y))
+		assert.Equal(t, Dim(1), flippedY.Cell(p.x, -1-p.y))
+	}
+	assert.Equal(t, qt.Population, flippedX.Population)
+	assert.True(t, qt == flippedX.FlipX())
+	assert.True(t, qt == flippedY.FlipY())
+
+	// flipping both ways is a half turn
+	assert.True(t, qt.Rotate90().Rotate90() == flippedX.FlipY())
+}
+
+func TestTranslate(t *testing.T) {
+	qt := glider().GrowToFit(20, 20)
+	for _, shift := range [][2]Dim{{0, 0}, {3, -5}, {8, 16}, {-64, 0}, {1 << 30, -(1 << 20)}, {-7, 1 << 12}} {
+		moved := qt.Translate(shift[0], shift[1])
+		assert.Equal(t, qt.Population, moved.Population, "%v", shift)
+		for _, p := range qt.livePoints() {
+			assert.Equal(t, Dim(1), moved.Cell(p.x+shift[0], p.y+shift[1]), "%v", shift)
+		}
+	}
+
+	// cell states move along
+	multi := qt.SetCellState(0, 0, 4)
+	for _, shift := range [][2]Dim{{3, -5}, {8, 16}} {
+		assert.Equal(t, State(4), multi.Translate(shift[0], shift[1]).CellState(shift[0], shift[1]), "%v", shift)
+	}
+
+	// aligned shifts back and forth give the same nodes
+	assert.True(t, qt.Translate(16, 8).Translate(-16, -8).Shrink() == qt.Shrink())
+	assert.True(t, EmptyTree(4) == EmptyTree(4).Translate(8, 8))
+}
@@ -0,0 +1,67 @@
+package quadtree
+
+import "sync/atomic"
+
+// Universe wraps a root quadtree for stateful simulations and counts the generations it has been stepped.
+// The state is published with an atomic pointer swap, so a render goroutine can read
+// Current() while a simulation goroutine calls Step() without ever blocking on it.
+// Step itself is meant to be called from a single goroutine.
+//
+// The root and the generation aren't exported as Root and Generation fields: a reader could see them torn
+// while Step updates them. Current, Generation and Snapshot read them instead.
+type Universe struct {
+	state   atomic.Pointer[universeState]
+	initial *Quadtree // root before the first Step, restored by Reset
+}
+
+// universeState is a root together with its generation, swapped as a whole so readers never see a mismatch
+type universeState struct {
+	root       *Quadtree
+	generation uint64
+}
+
+// NewUniverse returns a Universe with qt as its current root at generation 0
+func NewUniverse(qt *Quadtree) *Universe {
+	u := &Universe{initial: qt}
+	u.state.Store(&universeState{qt, 0})
+	return u
+}
+
+// Current returns the current root. Trees are immutable, so the result is a consistent snapshot.
+func (u *Universe) Current() *Quadtree {
+	return u.state.Load().root
+}
+
+// Generation returns the number of generations computed since the Universe was created or reset
+func (u *Universe) Generation() uint64 {
+	return u.state.Load().generation
+}
+
+// Snapshot returns the current root and its generation as a consistent pair
+func (u *Universe) Snapshot() (*Quadtree, uint64) {
+	s := u.state.Load()
+	return s.root, s.generation
+}
+
+// Step computes the next generation and swaps it in as the new root
+func (u *Universe) Step() {
+	s := u.state.Load()
+	// NextGen grows the tree before advancing, so it always computes exactly one generation
+	u.state.Store(&universeState{s.root.NextGen(), s.generation + 1})
+}
+
+// Reset restores the root the Universe was created with and sets the generation back to 0
+func (u *Universe) Reset() {
+	u.state.Store(&universeState{u.initial, 0})
+}
+
+// PopulationSeries steps the Universe n times and returns the population after each step.
+// The population of a root is known from its construction, so reading it costs nothing beyond the step.
+func (u *Universe) PopulationSeries(n int) []Dim {
+	series := make([]Dim, 0, n)
+	for i := 0; i < n; i++ {
+		u.Step()
+		series = append(series, u.Current().Population)
+	}
+	return series
+}
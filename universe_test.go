@@ -0,0 +1,83 @@
+package quadtree
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blinker returns a level 3 tree with a horizontal blinker centered at the origin
+func blinker() *Quadtree {
+	qt := EmptyTree(3)
+	qt = qt.SetCell(-1, 0, 1)
+	qt = qt.SetCell(0, 0, 1)
+	qt = qt.SetCell(1, 0, 1)
+	return qt
+}
+
+func TestUniverseStep(t *testing.T) {
+	start := blinker()
+	u := NewUniverse(start)
+	assert.Equal(t, start, u.Current())
+	assert.Equal(t, uint64(0), u.Generation())
+
+	u.Step()
+	qt := u.Current()
+	assert.Equal(t, uint64(1), u.Generation())
+	assert.Equal(t, Dim(3), qt.Population)
+	assert.Equal(t, Dim(1), qt.Cell(0, -1))
+	assert.Equal(t, Dim(1), qt.Cell(0, 0))
+	assert.Equal(t, Dim(1), qt.Cell(0, 1))
+
+	// the old snapshot is untouched
+	assert.Equal(t, Dim(1), start.Cell(-1, 0))
+}
+
+func TestUniverseConcurrentRead(t *testing.T) {
+	u := NewUniverse(blinker())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			qt, gen := u.Snapshot()
+			assert.Equal(t, Dim(3), qt.Population)
+			// the blinker has period 2, so root and generation have to match
+			if gen%2 == 0 {
+				assert.Equal(t, Dim(1), qt.Cell(-1, 0))
+			} else {
+				assert.Equal(t, Dim(1), qt.Cell(0, -1))
+			}
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		u.Step()
+	}
+	wg.Wait()
+}
+
+func TestUniverseReset(t *testing.T) {
+	start := blinker()
+	u := NewUniverse(start)
+	u.Step()
+	u.Step()
+	u.Step()
+	assert.NotEqual(t, start, u.Current())
+	assert.Equal(t, uint64(3), u.Generation())
+
+	u.Reset()
+	assert.Equal(t, start, u.Current())
+	assert.Equal(t, uint64(0), u.Generation())
+}
+
+func TestPopulationSeries(t *testing.T) {
+	u := NewUniverse(blinker())
+	assert.Equal(t, []Dim{3, 3, 3}, u.PopulationSeries(3))
+	assert.Equal(t, uint64(3), u.Generation())
+
+	// an L of three cells becomes a block
+	u = NewUniverse(treeWithCells([2]Dim{0, 0}, [2]Dim{1, 0}, [2]Dim{0, 1}))
+	assert.Equal(t, []Dim{4, 4}, u.PopulationSeries(2))
+	assert.Empty(t, u.PopulationSeries(0))
+}